@@ -11,29 +11,204 @@ The commands are:
 
         fetch       fetch a remote dependency
         rebuild     rebuild dependencies from manifest
+        restore     fetch the dependencies missing from the vendor directory
         update      update a local dependency
         list        list dependencies one per line
         delete      delete a local dependency
+        verify      check vendored files against the manifest
+        status      compare the vendor directory with the manifest
+        repair      rebuild a lost manifest from the vendor directory
+        migrate     write a go.mod from the manifest
+        import-lock vendor the dependencies pinned by another tool
+        prune       remove dependencies that are not imported
+        clean       remove files not part of any dependency
+        outdated    list dependencies with newer upstream revisions
+        diff        preview the manifest changes of an update
+        license     list the licenses of vendored dependencies
+        graph       print the dependency graph in DOT format
+        why         explain why a dependency is vendored
+        cache       manage the repository cache
+        completion  print a shell completion script
+        version     print the version of gvt
+
+All commands accept -v to print debug messages and -q to only print errors.
+They also accept -target dir to use dir as the project directory instead of
+the current directory, and -vendor-dir dir to use dir, relative to the project
+directory, instead of vendor. It defaults to $GVT_VENDOR_DIR if set. The go
+tool only looks in directories named vendor. The files gvt reads from the
+current directory, like .gvtignore, are read from the project directory.
+
+The commands changing the vendor directory lock vendor/.gvt.lock, so that
+concurrent ones don't corrupt it, and fail if another gvt holds it. With
+-lock-timeout duration, which all commands accept, they wait up to duration
+for it instead.
+
+The exit status is 0 on success, 2 for bad flags or arguments, 3 if a
+repository could not be fetched, 4 if the vendor directory does not match
+the manifest, as reported by verify, status, and the -n flag of prune and
+clean, 5 if a Go file could not be parsed, 6 if the manifest could not be
+read or written, and 1 for any other error.
 
 Use "gvt help [command]" for more information about a command.
 
+Additional help topics:
+
+        network     flags of the commands accessing remote repositories
+        parsing     flags of the commands parsing the imports of the project
+
+Use "gvt help [topic]" for more information about that topic.
+
 
 Fetch a remote dependency
 
 Usage:
-        gvt fetch [-branch branch | -revision rev | -tag tag] [-precaire] [-no-recurse] importpath
+        gvt fetch [-branch branch | -revision rev | -tag tag] [-precaire] [-insecure-host host] [-no-recurse] [-max-depth n] [-pin importpath=rev] [-ignore pattern] [-provided prefix] [-platforms list] [-replace importpath=dir] [-replace-symlink] [-self importpath] [-tests] [-prune-files] [-flatten] [-minimal] [-shallow] [-allow licenses] [-deny licenses] [-json] importpath | -import-list file
 
 fetch vendors an upstream import path.
 
 The import path may include a url scheme. This may be useful when fetching dependencies
 from private repositories that cannot be probed.
 
+gopkg.in import paths are fetched from the GitHub repository behind them, at
+the branch or tag with the highest version matching the one in the path, as
+gopkg.in would serve it. The manifest records that repository, and the
+version, as in "v2", as the branch so that update follows it.
+
+Missing dependencies are then fetched recursively. Each of them is vendored
+from the root of its repository, so that packages sharing a repository are
+fetched once and recorded in a single manifest entry. The manifest records
+them as transitive. Fetching one of them again records it as direct instead.
+Imports which are not valid import paths, as the go command would reject
+them, like those of templated files, are skipped with a warning.
+
+A dependency in the manifest whose directory is missing from the vendor
+directory, as after an interrupted run, is not considered vendored: it is
+fetched again at the revision the manifest records.
+
+Afterwards, a warning is printed for each dependency that nothing in the
+project imports, directly or through other dependencies, for review. The
+fetched import path is exempt, as it might not be imported yet. Nothing is
+deleted, see prune.
+
+A warning is also printed for each vendored package whose import comment,
+as in package foo // import "example.com/foo", declares another canonical
+import path, as building against it through the vendored one breaks.
+
+Private repositories over HTTPS can be accessed with the credentials of a
+netrc file, see -netrc, or with a token in an environment variable named
+after the host, as in GVT_TOKEN_GITHUB_COM for github.com. The token may be
+prefixed with a login and a colon. Credentials are never written to the
+manifest or printed.
+
+Metadata requests and vcs commands go through the proxies set in the
+HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. This is
+independent of -precaire, which only allows insecure protocols.
+
+Metadata requests rate limited by the host, with a 429 status or a 403 with
+a Retry-After header, are retried up to 3 times after waiting as long as
+the host asks, if that is at most a minute.
+
 Flags:
 	-branch branch
 		fetch from the name branch. If not supplied the default upstream
 		branch will be used.
 	-no-recurse
 		do not fetch recursively.
+	-import-list file
+		fetch the import paths listed in file, one per line, instead of
+		a single one, as produced by another tool. Each is vendored from
+		the root of its repository, like recursive dependencies. Blank
+		lines, lines starting with # and import paths which don't need
+		to be fetched, like those of the standard library, are skipped,
+		as are those already vendored. Malformed import paths are logged
+		and skipped. It can't be used with -branch, -tag or -revision,
+		which would apply to every repository.
+	-max-depth n
+		only fetch recursive dependencies up to n imports away from the
+		fetched package. Deeper imports are logged and left missing.
+		If not supplied there is no limit.
+	-pin importpath=rev
+		fetch the recursive dependency with the given repository root import
+		path at revision rev, which may also be a tag, instead of at the head
+		of the default branch. Can be supplied multiple times.
+	-ignore pattern
+		do not fetch recursive dependencies whose import path, or one of
+		its parents, matches pattern, as in "example.com/internal/*".
+		Can be supplied multiple times. Patterns are also read, one per
+		line, from a .gvtignore file in the project directory.
+	-provided prefix
+		never fetch the imports which are or are inside prefix, because
+		the platform the project runs on provides them. The App Engine
+		SDK imports "appengine" and "appengine_internal" are always
+		provided. Can be supplied multiple times.
+	-platforms list
+		evaluate the build constraints of each file for all the comma
+		separated GOOS/GOARCH pairs, as in "linux/amd64,windows/amd64",
+		and use the file if any of them matches, so that the imports
+		needed by every platform are fetched. Can be supplied multiple times.
+		If not supplied only the host platform is considered.
+	-replace importpath=dir
+		vendor the dependency with the given repository root import path,
+		fetched directly or recursively, by copying the local directory
+		dir instead of fetching it, for local development. dir may be
+		relative to the project directory. The manifest records dir
+		instead of a repository and revision, verify and status report
+		the dependency as "replaced", and update and rebuild copy dir
+		again. Can be supplied multiple times. Replacements are also read,
+		one per line, from a .gvtreplace file in the project directory.
+	-replace-symlink
+		symlink the vendored copy of the replaced dependencies to their
+		local directory instead of copying it, so that changes show up
+		without copying again.
+	-self importpath
+		the import path of the project. Its packages, including the internal
+		ones, are never fetched even if a dependency imports them, which
+		is reported along with the chain of imports leading to them. If not
+		supplied it is deduced from the location of the project in GOPATH.
+	-tests
+		also fetch the dependencies of the package tests, including
+		external test packages. Only the tests of the fetched package
+		are considered, not those of its dependencies. Dependencies only
+		the tests need are marked as such in the manifest.
+	-shallow
+		only clone the last commit of git repositories, which is faster
+		for large ones. Repositories fetched at a -revision or -pin, and
+		other VCS, are still cloned in full. Recorded in the manifest.
+	-prune-files
+		remove test files, testdata directories, CI configuration and
+		markdown documentation from the fetched dependencies. License,
+		copying and notice files are always kept. This is recorded in the
+		manifest, so that update and rebuild prune them the same way.
+		Can't be used with -tests.
+	-flatten
+		move the packages in the vendor directories of the fetched
+		dependencies to the top-level vendor directory, recording them
+		in the manifest as hoisted. A package already vendored at a
+		different version is reported and left nested. This is
+		recorded in the manifest, so that update and rebuild flatten
+		them the same way.
+	-minimal
+		once all the dependencies are fetched, only keep the packages
+		of each that the project imports, even through other
+		dependencies, along with their directories without Go files
+		and the license files. A dependency none of whose packages is
+		imported yet is kept whole. The kept packages are recorded in
+		the manifest: rebuild keeps the same, update computes them again.
+		Fetching a package which was not kept, directly or recursively,
+		copies it back at the recorded revision and records it as kept.
+	-allow licenses
+		only vendor dependencies whose license, as detected by the license
+		command, is one of the comma separated SPDX identifiers. Use
+		"none" and "unknown" to allow dependencies without a license file
+		or with an unrecognized one. Can be supplied multiple times.
+	-deny licenses
+		never vendor dependencies with one of the comma separated licenses,
+		as in "GPL-2.0,GPL-3.0,AGPL-3.0". Can be supplied multiple times.
+	-json
+		print the summary of the fetch, the number of dependencies
+		vendored and of those needed but already vendored, the bytes
+		written and the duration in nanoseconds, as a JSON object on the
+		standard output. Otherwise it is logged.
 	-tag tag
 		fetch the specified tag. If not supplied the default upstream
 		branch will be used.
@@ -42,11 +217,20 @@ Flags:
 		revision supplied, the latest available will be supplied.
 	-precaire
 		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: resolve, fetch-start, fetch-done, skip and error.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
 
 Rebuild dependencies from manifest
 
 Usage:
-        gvt rebuild
+        gvt rebuild [-j n] [-per-host n] [-keep-going]
 
 rebuild fetches the dependencies listed in the manifest.
 
@@ -58,36 +242,129 @@ source, for example if .gitignore includes lines like
 
 Note that such a setup requires "gvt rebuild" to build the source, relies on
 the availability of the dependencies repositories and breaks "go get".
+All the dependencies are fetched again, restore only fetches the missing
+ones.
+
+Dependencies replaced with fetch -replace are copied, or symlinked, again
+from their local directory. Packages hoisted with fetch -flatten are moved
+again out of the vendor directory of the dependency they came from. Only
+the packages recorded by fetch -minimal are kept.
+
+Flags:
+	-j n
+		fetch up to n dependencies concurrently. Defaults to 1.
+		Dependencies vendored from the same repository at the same
+		revision share a single checkout.
+	-per-host n
+		fetch up to n dependencies concurrently from the same host,
+		so that -j doesn't get a single host to throttle the clones.
+		Dependencies from different hosts can still use all of -j.
+		Defaults to 2.
+	-keep-going
+		when a dependency fails to fetch, carry on with the others and
+		report all the failures at the end.
+	-precaire
+		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: resolve, fetch-start, fetch-done, skip and error.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
+
+Fetch the dependencies missing from the vendor directory
+
+Usage:
+        gvt restore [-j n] [-per-host n] [-keep-going]
+
+restore fetches the dependencies listed in the manifest which are not
+already vendored, recreating the vendor directory of a fresh checkout which
+only includes the manifest.
+
+Like rebuild, each dependency is fetched at the revision the manifest
+records, without looking at the imports of the project or resolving its
+dependencies again. Unlike rebuild, dependencies already present in the
+vendor directory, with the checksum the manifest records if any, are left
+alone, so running restore again does nothing. A dependency is fetched again
+if packages hoisted from it with fetch -flatten are missing, to put them back.
 
 Flags:
+	-j n
+		fetch up to n dependencies concurrently. Defaults to 1.
+		Dependencies vendored from the same repository at the same
+		revision share a single checkout.
+	-per-host n
+		fetch up to n dependencies concurrently from the same host,
+		so that -j doesn't get a single host to throttle the clones.
+		Dependencies from different hosts can still use all of -j.
+		Defaults to 2.
+	-keep-going
+		when a dependency fails to fetch, carry on with the others and
+		report all the failures at the end.
 	-precaire
 		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: fetch-start, fetch-done, skip and error.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
 
 Update a local dependency
 
 Usage:
-        gvt update [-all] import
+        gvt update [-all] [-revision rev] [-keep-going] import...
 
 update will replaces the source with the latest available from the head of the master branch.
 
 Updating from one copy of a dependency to another comes with several restrictions.
 The first is you can only update to the head of the branch your dependency was vendored from, switching branches is not supported.
 The second restriction is if you have used -tag or -revision while vendoring a dependency, your dependency is "headless"
-(to borrow a term from git) and cannot be updated.
-
-To update across branches, or from one tag/revision to another, you must first use delete to remove the dependency, then
+(to borrow a term from git) and can only be moved to another revision with -revision.
+
+Dependencies fetched with -shallow are updated with a shallow clone too.
+Dependencies replaced with fetch -replace are copied, or symlinked, again
+from their local directory.
+Dependencies fetched with -flatten have their vendor directory moved again
+to the top-level one, replacing the packages hoisted from the old version.
+Those packages can't be updated on their own.
+Dependencies fetched with -minimal are trimmed again to the packages the
+project imports from the new version.
+
+To update across branches, or to a tag, you must first use delete to remove the dependency, then
 fetch [-tag | -revision | -branch ] [-precaire] to replace it.
 
 Flags:
 	-all
-		will update all dependencies in the manifest, otherwise only the dependency supplied.
+		will update all dependencies in the manifest, otherwise only the dependencies supplied.
+	-keep-going
+		when a dependency fails to update, carry on with the others and
+		report all the failures at the end.
 	-precaire
 		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-revision rev
+		update a single dependency to the given revision instead of the
+		head of its branch. The dependency is then headless.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: resolve, fetch-start, fetch-done, skip and error.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
 
 List dependencies one per line
 
 Usage:
-        gvt list [-f format]
+        gvt list [-f format | -json] [-direct]
 
 list formats the contents of the manifest file.
 
@@ -95,6 +372,14 @@ Flags:
 	-f
 		controls the template used for printing each manifest entry. If not supplied
 		the default value is "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}"
+		The other fields of the manifest entries, like FetchedAt, the time
+		the dependency was last fetched or updated, can be used too.
+	-json
+		print the manifest entries as a single JSON array instead, using the
+		same field names as the manifest.
+	-direct
+		only list the direct dependencies, leaving out the ones fetched
+		recursively because another dependency imports them.
 
 Delete a local dependency
 
@@ -103,9 +388,424 @@ Usage:
 
 delete removes a dependency from the vendor directory and the manifest
 
+The packages hoisted out of it by fetch -flatten are deleted with it.
+
 Flags:
 	-all
 		remove all dependencies
 
+Check vendored files against the manifest
+
+Usage:
+        gvt verify
+
+verify checks that the vendored source of every dependency in the manifest
+matches the checksum recorded when it was fetched.
+
+Each dependency that does not match is printed along with the reason,
+"missing" if its directory does not exist and "modified" if files were
+changed, added or removed. Dependencies fetched without a checksum are
+reported as "unverified", and dependencies replaced by a local directory
+with fetch -replace as "replaced" along with it, but do not cause a
+failure.
+
+verify does not access the network. It exits with status 4 if any
+dependency fails verification.
+
+Compare the vendor directory with the manifest
+
+Usage:
+        gvt status
+
+status reports the differences between the manifest and the vendor directory.
+
+It prints the same lines as verify, "missing", "modified", "unverified" and
+"replaced", followed by an "untracked" line for each directory of the vendor
+directory holding files that are not part of any dependency in the manifest.
+
+status does not access the network. It exits with status 4 if anything
+but unverified or replaced dependencies is reported.
+
+Rebuild a lost manifest from the vendor directory
+
+Usage:
+        gvt repair [-f]
+
+repair writes a new manifest describing the dependencies found in the vendor
+directory, for when the manifest was lost or corrupted but the vendored
+files are intact.
+
+The directories holding files are grouped into dependencies by repository
+root. For github.com, bitbucket.org, gitlab.com and golang.org/x that is the
+first three elements of the import path, for gopkg.in the elements up to
+the version, as in gopkg.in/yaml.v2, otherwise it is the top most directory
+holding files. A directory with git metadata is always a dependency of its own, and
+its revision, branch and remote are read from it.
+
+Vendored files don't usually carry VCS metadata, so the revision of most
+dependencies will be unknown. They are listed in a warning, and recorded
+with their checksum so that verify still detects changes. Use update or
+fetch to record a known revision again.
+
+Flags:
+	-f
+		replace the manifest even if it can still be read.
+
+Write a go.mod from the manifest
+
+Usage:
+        gvt migrate [-module path] [-remove-vendor]
+
+migrate writes a go.mod file in the current directory requiring every
+dependency in the manifest at its recorded revision.
+
+Dependencies are grouped by the root of their repository, which becomes
+the module path. The manifest only records commit hashes, so those are
+written as the required versions. Run "go mod tidy" afterwards to have
+the go command turn them into canonical versions and write go.sum. A
+go.sum can't be derived from the vendored files, since it holds checksums
+of the module archives.
+
+migrate refuses to overwrite an existing go.mod. The vendor directory is
+left in place unless -remove-vendor is supplied, run "go mod vendor" to
+bring it in a state usable with -mod=vendor.
+
+Flags:
+	-module path
+		the module path of the project. If not supplied it is inferred from
+		the location of the current directory in GOPATH.
+	-remove-vendor
+		remove the vendor directory, including the manifest, once go.mod
+		is written.
+
+Vendor the dependencies pinned by another tool
+
+Usage:
+        gvt import-lock [-precaire] [-tests] [lockfile]
+
+import-lock vendors each repository pinned in the lock file of another
+vendoring tool, at the pinned revision, and records it in the manifest.
+
+The dependency graph is not resolved again: the lock file is trusted to
+be complete. Each repository is vendored from its root, so all the packages
+listed for it are included. Repositories already in the manifest are skipped.
+A repository that can't be fetched is reported, and the others are still
+imported.
+
+If lockfile is not supplied, the first lock file found in the current
+directory is read. Supported formats:
+
+	Gopkg.lock		dep
+	glide.lock		Glide
+	Godeps/Godeps.json	godep
+
+godep records packages rather than repositories. The repository of each of
+them is deduced when fetching, and it is only fetched once.
+
+Flags:
+	-tests
+		also import the dependencies only needed by tests, if the lock
+		file tells them apart.
+	-precaire
+		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: resolve, fetch-start, fetch-done, skip and error.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
+
+Remove dependencies that are not imported
+
+Usage:
+        gvt prune [-n] [-tests] [-self importpath] [-follow-symlinks] [-skip-errors] [-provided prefix] [-exclude-dir pattern] [-platforms list]
+
+prune removes from the vendor directory and the manifest the dependencies
+that are not needed by the project any more.
+
+A dependency is needed if any of its packages is imported by the source of
+the project, outside the vendor directory, or by another needed dependency.
+Imports of the project's own packages don't count, so a copy of the project
+in its own vendor directory is removed. The import path of each removed
+dependency is printed.
+
+A dependency that needed packages were hoisted out of by fetch -flatten is
+kept, as they are fetched again through it. The packages hoisted out of a
+removed dependency are removed with it.
+
+Flags:
+	-n
+		only print the dependencies that would be removed, and exit with
+		status 4 if there are any.
+	-tests
+		keep the dependencies needed by the tests of the project.
+	-self importpath
+		the import path of the project. If not supplied it is deduced from
+		the location of the project in GOPATH.
+
+The parsing flags, like -provided and -platforms, are also accepted, see
+"gvt help parsing".
+
+Remove files not part of any dependency
+
+Usage:
+        gvt clean [-n] [-f]
+
+clean removes from the vendor directory the files and directories which are
+not part of any dependency in the manifest, like leftovers of interrupted
+commands or stray editor files, so that it matches the manifest exactly.
+The manifest itself is kept.
+
+Each path removed is printed, relative to the vendor directory. clean asks
+for confirmation first, and refuses to run without -f if the standard input
+is not a terminal.
+
+Flags:
+	-n
+		only print the paths that would be removed, and exit with status
+		4 if there are any.
+	-f
+		remove them without asking for confirmation.
+
+List dependencies with newer upstream revisions
+
+Usage:
+        gvt outdated [-precaire] [-insecure-host host]
+
+outdated checks the head of the branch each dependency was fetched from and
+prints the dependencies in the manifest along with their current and latest
+revision. The vendor directory and the manifest are left untouched.
+
+Dependencies fetched with -tag or -revision can't be updated and are
+reported as "pinned", and the ones replaced with fetch -replace as
+"replaced". A dependency whose repository can't be checked is
+reported as "error", the other ones are still listed.
+
+Flags:
+	-precaire
+		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
+
+Preview the manifest changes of an update
+
+Usage:
+        gvt diff [-all] [-precaire] [-insecure-host host] [importpath...]
+
+diff prints as a unified diff the changes update would make to the manifest,
+with the same arguments, without writing anything. The vendor directory is
+left untouched too.
+
+Only the repositories are checked, as with outdated, so the checksums and
+fetch times update also records are left out, as are the packages update
+would move out of the vendor directory of the dependencies fetched with
+-flatten. Pinned, replaced and hoisted dependencies are never updated.
+
+Like prune -n, it fails with exit code 4 if the manifest would change.
+
+Flags:
+	-all
+		preview the update of all the dependencies in the manifest,
+		otherwise only the dependencies supplied.
+	-precaire
+		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
+
+List the licenses of vendored dependencies
+
+Usage:
+        gvt license [-json]
+
+license looks for a LICENSE, COPYING or NOTICE file in the vendored directory
+of every dependency in the manifest and prints the license it detects, as an
+SPDX identifier such as MIT or Apache-2.0.
+
+Detection is a heuristic based on well known phrases of the license texts.
+Dependencies with a file whose license isn't recognized are reported as
+"unknown", those without any such file as "none", and those whose
+directory doesn't exist as "missing".
+
+Flags:
+	-json
+		print a JSON array of objects with the importpath, license and
+		file fields instead.
+
+Print the dependency graph in DOT format
+
+Usage:
+        gvt graph [-pkg importpath] [-tests] [-self importpath] [-follow-symlinks] [-skip-errors] [-provided prefix] [-exclude-dir pattern] [-platforms list]
+
+graph prints the graph of the dependencies in the manifest in the Graphviz DOT
+format, ready to be piped into a command like "dot -Tsvg".
+
+Each node is the project or a dependency, and there is an edge from a node
+to each dependency one of its packages imports. Dependencies nothing imports
+are printed as nodes without edges.
+
+Flags:
+	-pkg importpath
+		only print the part of the graph reachable from the dependency, or
+		the project, containing the package importpath.
+	-tests
+		include the imports of the tests of the project.
+	-self importpath
+		the import path of the project, used as the name of its node. If not
+		supplied it is deduced from the location of the project in GOPATH.
+
+The parsing flags, like -provided and -platforms, are also accepted, see
+"gvt help parsing".
+
+Explain why a dependency is vendored
+
+Usage:
+        gvt why [-tests] [-self importpath] [-follow-symlinks] [-skip-errors] [-provided prefix] [-exclude-dir pattern] [-platforms list] importpath
+
+why prints the shortest chain of imports from each package of the project
+that needs importpath, which can be a package or a dependency in the manifest,
+one chain per line.
+
+If no package of the project needs it, why says so. Such a dependency can be
+removed with prune.
+
+Flags:
+	-tests
+		include the imports of the tests of the project.
+	-self importpath
+		the import path of the project, used to name its packages. If not
+		supplied it is deduced from the location of the project in GOPATH.
+
+The parsing flags, like -provided and -platforms, are also accepted, see
+"gvt help parsing".
+
+Manage the repository cache
+
+Usage:
+        gvt cache clear|dir
+
+cache manages the cache of the repositories deduced from import paths.
+
+Finding the repository of an import path takes network requests, probing
+the protocols it is served with or asking a vanity import path for its
+go-import metadata. The commands accessing the network cache the result for
+24 hours in the gvt directory of the user cache directory, as in
+$XDG_CACHE_HOME/gvt, or the directory set in $GVT_CACHE_DIR. The fetch,
+update, rebuild, outdated and import-lock commands accept -no-cache to
+neither read nor write it.
+
+	clear	remove the cached repositories
+	dir	print the cache directory
+
+Print a shell completion script
+
+Usage:
+        gvt completion bash|zsh|fish
+
+completion prints a script completing the commands of gvt and their flags
+in the given shell. For update, delete and why, the import paths in the
+manifest of the current directory are completed as well.
+
+To enable it, add to the shell configuration:
+
+	bash	source <(gvt completion bash)
+	zsh	source <(gvt completion zsh)
+	fish	gvt completion fish | source
+
+Print the version of gvt
+
+Usage:
+        gvt version
+
+version prints the version of gvt, the version of Go it was built with and
+the version of the manifest format it writes. Manifests of a later version
+are rejected by all commands, so that an older gvt can't drop what it
+doesn't know about.
+
+Flags of the commands accessing remote repositories
+
+The commands accessing remote repositories, fetch, rebuild, restore, update,
+import-lock, outdated and diff, accept these flags.
+
+	-retries n
+		retry a checkout failing because of a network error up to n
+		times, waiting longer after each attempt. Defaults to 0.
+	-retry-wait duration
+		how long to wait before the first retry, doubled after each one
+		up to a minute. Must not be negative. Defaults to 2s.
+	-timeout duration
+		how long to wait for the go-import metadata of a vanity import
+		path. Defaults to 30s.
+	-netrc file
+		read the credentials of private repositories from file instead
+		of $NETRC or ~/.netrc. They are used for git repositories and
+		go-import metadata over HTTPS.
+	-mirror prefix=mirror
+		clone the repositories whose URL, without the scheme, starts with
+		prefix from mirror instead, as in
+		"github.com=git.internal.example.com/mirror". The manifest keeps
+		the original repository. The longest matching prefix is used.
+		Can be supplied multiple times. The go-import metadata of vanity
+		import paths is still requested from their host.
+	-no-cache
+		neither read nor write the cache of the repositories deduced
+		from import paths, see cache.
+	-dep-timeout duration
+		give up on a checkout attempt after duration, killing the VCS
+		commands. It counts as a network error for -retries.
+	-deadline duration
+		give up on the whole command after duration.
+	-offline
+		never access the network, failing with the import path of the
+		first dependency which would need to be fetched. rebuild and
+		restore then only check that the dependencies are already
+		vendored and unmodified.
+	-vcs [prefix=]vcs
+		use the version control system vcs, one of git, hg, bzr or svn,
+		for the repositories of the import paths under prefix, or of all
+		of them, instead of trying in turn those their host could serve.
+		Import paths under prefix without go-import metadata are taken as
+		the root of their repository. Can be repeated.
+
+Flags of the commands parsing the imports of the project
+
+The commands parsing the imports of the project, prune, graph and why,
+accept these flags.
+
+	-follow-symlinks
+		also parse the directories symlinks in the project point to, each
+		only once. They are skipped otherwise.
+	-skip-errors
+		skip the files of the project which can't be parsed, warning
+		about each, instead of failing.
+	-provided prefix
+		treat the imports which are or are inside prefix as provided by
+		the platform, like the standard library. The App Engine SDK
+		imports "appengine" and "appengine_internal" are always
+		provided. Can be supplied multiple times.
+	-exclude-dir pattern
+		do not parse the project directories whose path, relative to the
+		project directory and slash separated, matches pattern, as in
+		"examples/*", nor anything inside them. Can be supplied multiple
+		times. The vendor directory, testdata directories and those
+		starting with a dot or an underscore are always skipped: patterns
+		only add to them, and skipping wins over -follow-symlinks.
+	-platforms list
+		evaluate the build constraints of each file for all the comma
+		separated GOOS/GOARCH pairs, as in "linux/amd64,windows/amd64",
+		and use the file if any of them matches, so that the imports
+		needed by every platform are considered. Can be supplied multiple times.
+		If not supplied only the host platform is considered.
+
 */
 package main
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var noCache bool // don't use the repository cache
+
+var cmdCache = &Command{
+	Name:      "cache",
+	UsageLine: "cache clear|dir",
+	Short:     "manage the repository cache",
+	Long: `cache manages the cache of the repositories deduced from import paths.
+
+Finding the repository of an import path takes network requests, probing
+the protocols it is served with or asking a vanity import path for its
+go-import metadata. The commands accessing the network cache the result for
+24 hours in the gvt directory of the user cache directory, as in
+$XDG_CACHE_HOME/gvt, or the directory set in $GVT_CACHE_DIR. The fetch,
+update, rebuild, outdated and import-lock commands accept -no-cache to
+neither read nor write it.
+
+	clear	remove the cached repositories
+	dir	print the cache directory
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 1 {
+			return usageErrorf("cache: one of clear or dir is required")
+		}
+		switch args[0] {
+		case "clear":
+			return vendor.ClearCache()
+		case "dir":
+			if vendor.CacheDir == "" {
+				return fmt.Errorf("cache: no user cache directory")
+			}
+			fmt.Println(vendor.CacheDir)
+			return nil
+		default:
+			return usageErrorf("cache: unknown subcommand %q", args[0])
+		}
+	},
+}
+
+// setupCache sets vendor.CacheDir, unless -no-cache was supplied.
+func setupCache() {
+	if noCache {
+		vendor.CacheDir = ""
+		return
+	}
+	if dir := os.Getenv("GVT_CACHE_DIR"); dir != "" {
+		vendor.CacheDir = dir
+		return
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		debugf("not caching repositories: %v", err)
+		return
+	}
+	vendor.CacheDir = filepath.Join(dir, "gvt")
+}
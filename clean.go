@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	cleanDryRun bool // only print what would be removed
+	cleanForce  bool // remove without asking
+)
+
+func addCleanFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&cleanDryRun, "n", false, "only print what would be removed")
+	fs.BoolVar(&cleanForce, "f", false, "remove without asking for confirmation")
+}
+
+var cmdClean = &Command{
+	Name:      "clean",
+	UsageLine: "clean [-n] [-f]",
+	Short:     "remove files not part of any dependency",
+	Long: `clean removes from the vendor directory the files and directories which are
+not part of any dependency in the manifest, like leftovers of interrupted
+commands or stray editor files, so that it matches the manifest exactly.
+The manifest itself is kept.
+
+Each path removed is printed, relative to the vendor directory. clean asks
+for confirmation first, and refuses to run without -f if the standard input
+is not a terminal.
+
+Flags:
+	-n
+		only print the paths that would be removed, and exit with status
+		4 if there are any.
+	-f
+		remove them without asking for confirmation.
+
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("clean takes no arguments")
+		}
+		return clean()
+	},
+	AddFlags: addCleanFlags,
+	Locks:    true,
+}
+
+func clean() error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+	stray, err := strayPaths(m)
+	if err != nil {
+		return err
+	}
+	if len(stray) == 0 {
+		return nil
+	}
+
+	for _, p := range stray {
+		fmt.Println(p)
+	}
+	if cleanDryRun {
+		return mismatchErrorf("%d paths are not part of any dependency", len(stray))
+	}
+	if !cleanForce {
+		if !isTerminal(os.Stdin) {
+			return usageErrorf("clean: use -f to remove files without confirmation")
+		}
+		fmt.Printf("remove %d paths? [y/N] ", len(stray))
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			fmt.Println()
+			return usageErrorf("clean: use -f to remove files without confirmation")
+		}
+		if a := strings.TrimSpace(answer); a != "y" && a != "Y" {
+			return nil
+		}
+	}
+
+	for _, p := range stray {
+		if err := vendor.RemoveAll(filepath.Join(vendorDir(), filepath.FromSlash(p))); err != nil {
+			return fmt.Errorf("could not remove %s: %v", p, err)
+		}
+	}
+	log.Printf("removed %d paths", len(stray))
+	return nil
+}
+
+// strayPaths returns the slash separated paths, relative to the vendor
+// directory, of the top most files and directories which are neither the
+// manifest, part of a dependency of m, nor a directory leading to one.
+func strayPaths(m *vendor.Manifest) ([]string, error) {
+	deps := make(map[string]bool)
+	parents := make(map[string]bool)
+	for _, d := range m.Dependencies {
+		deps[d.Importpath] = true
+		for p := filepath.ToSlash(filepath.Dir(d.Importpath)); p != "."; p = filepath.ToSlash(filepath.Dir(p)) {
+			parents[p] = true
+		}
+	}
+
+	root := vendorDir()
+	var stray []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+			return err
+		}
+		if path == root || path == manifestFile() || path == lockFile() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case deps[rel] && info.IsDir():
+			return filepath.SkipDir
+		case deps[rel]:
+			// a replaced dependency symlinked to its local directory
+			return nil
+		case info.IsDir() && parents[rel]:
+			return nil
+		}
+		stray = append(stray, rel)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return stray, err
+}
+
+// isTerminal reports whether f may be a terminal. Other character devices,
+// like /dev/null, are caught when reading the answer fails.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
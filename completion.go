@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var cmdCompletion = &Command{
+	Name:      "completion",
+	UsageLine: "completion bash|zsh|fish",
+	Short:     "print a shell completion script",
+	Long: `completion prints a script completing the commands of gvt and their flags
+in the given shell. For update, delete and why, the import paths in the
+manifest of the current directory are completed as well.
+
+To enable it, add to the shell configuration:
+
+	bash	source <(gvt completion bash)
+	zsh	source <(gvt completion zsh)
+	fish	gvt completion fish | source
+`,
+}
+
+// Run is set here rather than in the literal above because it reads
+// commands, which holds cmdCompletion.
+func init() {
+	cmdCompletion.Run = func(ctx context.Context, args []string) error {
+		if len(args) != 1 {
+			return usageErrorf("completion: a single shell is required")
+		}
+		switch args[0] {
+		case "bash":
+			bashCompletion(os.Stdout)
+		case "zsh":
+			zshCompletion(os.Stdout)
+		case "fish":
+			fishCompletion(os.Stdout)
+		default:
+			return usageErrorf("completion: unsupported shell %q", args[0])
+		}
+		return nil
+	}
+}
+
+// importpathCommands are the commands taking import paths from the manifest.
+var importpathCommands = []string{"update", "delete", "why"}
+
+// listImportpaths lists the import paths in the manifest from a script.
+const listImportpaths = `gvt list -f '{{.Importpath}}' 2>/dev/null`
+
+// commandFlags returns the flags of command, including the ones accepted
+// by all commands, in lexical order.
+func commandFlags(command *Command) []*flag.Flag {
+	// the flags are bound to the usual variables, which only reverts them
+	// to their defaults
+	fs := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	addGlobalFlags(fs)
+	if command.AddFlags != nil {
+		command.AddFlags(fs)
+	}
+	var flags []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) { flags = append(flags, f) })
+	return flags
+}
+
+func commandNames() string {
+	names := []string{"help"}
+	for _, c := range commands {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// helpNames returns the names "gvt help" accepts, the commands and the
+// help topics.
+func helpNames() string {
+	names := []string{commandNames()}
+	for _, t := range helpTopics {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func flagNames(command *Command) string {
+	var names []string
+	for _, f := range commandFlags(command) {
+		names = append(names, "-"+f.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func bashCompletion(w io.Writer) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `# bash completion for gvt
+
+_gvt() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" words=""
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W %q -- "$cur"))
+		return
+	fi
+	case "$cur" in
+	-*)
+		case "${COMP_WORDS[1]}" in
+`, commandNames())
+	for _, c := range commands {
+		fmt.Fprintf(&b, "\t\t%s) words=%q ;;\n", c.Name, flagNames(c))
+	}
+	fmt.Fprintf(&b, `		esac
+		;;
+	*)
+		case "${COMP_WORDS[1]}" in
+		help) words=%q ;;
+		%s) words="$(%s)" ;;
+		*) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+		esac
+		;;
+	esac
+	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+
+complete -F _gvt gvt
+`, helpNames(), strings.Join(importpathCommands, "|"), listImportpaths)
+	w.Write(b.Bytes())
+}
+
+func zshCompletion(w io.Writer) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `#compdef gvt
+# zsh completion for gvt
+
+_gvt() {
+	if (( CURRENT == 2 )); then
+		compadd -- %s
+		return
+	fi
+	if [[ $words[CURRENT] == -* ]]; then
+		case $words[2] in
+`, commandNames())
+	for _, c := range commands {
+		fmt.Fprintf(&b, "\t\t%s) compadd -- %s ;;\n", c.Name, flagNames(c))
+	}
+	fmt.Fprintf(&b, `		esac
+		return
+	fi
+	case $words[2] in
+	help) compadd -- %s ;;
+	%s) compadd -- ${(f)"$(%s)"} ;;
+	*) _files ;;
+	esac
+}
+
+compdef _gvt gvt
+`, helpNames(), strings.Join(importpathCommands, "|"), listImportpaths)
+	w.Write(b.Bytes())
+}
+
+func fishCompletion(w io.Writer) {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "# fish completion for gvt")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "complete -c gvt -n __fish_use_subcommand -f -a help -d 'print the documentation of a command'")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c gvt -n __fish_use_subcommand -f -a %s -d %s\n", c.Name, fishQuote(c.Short))
+	}
+	for _, c := range commands {
+		for _, f := range commandFlags(c) {
+			fmt.Fprintf(&b, "complete -c gvt -n '__fish_seen_subcommand_from %s' -o %s -d %s\n", c.Name, f.Name, fishQuote(f.Usage))
+		}
+	}
+	fmt.Fprintf(&b, "complete -c gvt -n '__fish_seen_subcommand_from help' -f -a %s\n", fishQuote(helpNames()))
+	fmt.Fprintf(&b, "complete -c gvt -n '__fish_seen_subcommand_from %s' -f -a %s\n", strings.Join(importpathCommands, " "), fishQuote("("+listImportpaths+")"))
+	w.Write(b.Bytes())
+}
+
+// fishQuote quotes s as a single argument for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
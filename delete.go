@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"path/filepath"
@@ -22,21 +23,23 @@ var cmdDelete = &Command{
 	Short:     "delete a local dependency",
 	Long: `delete removes a dependency from the vendor directory and the manifest
 
+The packages hoisted out of it by fetch -flatten are deleted with it.
+
 Flags:
 	-all
 		remove all dependencies
 
 `,
-	Run: func(args []string) error {
+	Run: func(ctx context.Context, args []string) error {
 		if len(args) != 1 && !deleteAll {
-			return fmt.Errorf("delete: import path or --all flag is missing")
+			return usageErrorf("delete: import path or --all flag is missing")
 		} else if len(args) == 1 && deleteAll {
-			return fmt.Errorf("delete: you cannot specify path and --all flag at once")
+			return usageErrorf("delete: you cannot specify path and --all flag at once")
 		}
 
 		m, err := vendor.ReadManifest(manifestFile())
 		if err != nil {
-			return fmt.Errorf("could not load manifest: %v", err)
+			return fmt.Errorf("could not load manifest: %w", err)
 		}
 
 		var dependencies []vendor.Dependency
@@ -55,6 +58,13 @@ Flags:
 		for _, d := range dependencies {
 			path := d.Importpath
 
+			if _, err := m.GetDependencyForImportpath(path); err != nil {
+				// hoisted from a dependency deleted already
+				continue
+			}
+			if err := removeHoisted(m, path); err != nil {
+				return err
+			}
 			if err := m.RemoveDependency(d); err != nil {
 				return fmt.Errorf("dependency could not be deleted: %v", err)
 			}
@@ -67,4 +77,5 @@ Flags:
 		return vendor.WriteManifest(manifestFile(), m)
 	},
 	AddFlags: addDeleteFlags,
+	Locks:    true,
 }
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var diffAll bool // preview the update of all dependencies
+
+func addDiffFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&diffAll, "all", false, "preview the update of all dependencies")
+	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.Var(insecureHostsFlag{}, "insecure-host", "host for which insecure protocols are allowed")
+	addNetworkFlags(fs)
+}
+
+var cmdDiff = &Command{
+	Name:      "diff",
+	UsageLine: "diff [-all] [-precaire] [-insecure-host host] [importpath...]",
+	Short:     "preview the manifest changes of an update",
+	Long: `diff prints as a unified diff the changes update would make to the manifest,
+with the same arguments, without writing anything. The vendor directory is
+left untouched too.
+
+Only the repositories are checked, as with outdated, so the checksums and
+fetch times update also records are left out, as are the packages update
+would move out of the vendor directory of the dependencies fetched with
+-flatten. Pinned, replaced and hoisted dependencies are never updated.
+
+Like prune -n, it fails with exit code 4 if the manifest would change.
+
+Flags:
+	-all
+		preview the update of all the dependencies in the manifest,
+		otherwise only the dependencies supplied.
+	-precaire
+		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) == 0 && !diffAll {
+			return usageErrorf("diff: import path or -all flag is missing")
+		} else if len(args) > 0 && diffAll {
+			return usageErrorf("diff: you cannot specify path and -all flag at once")
+		}
+		return diff(ctx, args)
+	},
+	AddFlags: addDiffFlags,
+}
+
+func diff(ctx context.Context, args []string) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+	old, err := ioutil.ReadFile(manifestFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, p := range args {
+		if _, err := m.GetDependencyForImportpath(p); err != nil {
+			return fmt.Errorf("could not get dependency: %v", err)
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, p := range args {
+		selected[p] = true
+	}
+	type head struct{ repository, branch string }
+	latest := make(map[head]string)
+	var updated int
+	for i, dep := range m.Dependencies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !diffAll && !selected[dep.Importpath] {
+			continue
+		}
+		if dep.Replace != "" || dep.Hoisted != "" || dep.Branch == "HEAD" {
+			continue
+		}
+		h := head{dep.Repository, dep.Branch}
+		rev, ok := latest[h]
+		if !ok {
+			rev, err = latestRevision(ctx, dep)
+			if err != nil {
+				return fmt.Errorf("could not check %s: %v", dep.Importpath, err)
+			}
+			latest[h] = rev
+		}
+		if rev != dep.Revision {
+			log.Printf("%s would be updated from %s to %s", dep.Importpath, dep.Revision, rev)
+			m.Dependencies[i].Revision = rev
+			updated++
+		}
+	}
+	if updated == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := vendor.EncodeManifest(&buf, m); err != nil {
+		return err
+	}
+	name := manifestFile()
+	if rel, err := filepath.Rel(projectDir, name); err == nil {
+		name = rel
+	}
+	fmt.Print(unifiedDiff(name, name, splitLines(string(old)), splitLines(buf.String())))
+	return mismatchErrorf("%d dependencies would be updated", updated)
+}
+
+// splitLines splits s into lines, without their line terminators.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffContext is the number of unchanged lines around each hunk.
+const diffContext = 3
+
+// unifiedDiff returns the unified diff from lines a, of file aname, to
+// lines b, of file bname, or "" if they are the same.
+func unifiedDiff(aname, bname string, a, b []string) string {
+	// the lines around the changes usually are most of the manifest, and
+	// need no table
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// ma[i:] and mb[j:]. What is left is small enough not to need Myers.
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			switch {
+			case ma[i] == mb[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// ops lists the lines of the edit script, each prefixed by ' ', '-'
+	// or '+', and the line numbers they start at in a and b.
+	type op struct {
+		kind byte
+		line string
+		i, j int
+	}
+	var ops []op
+	for k := 0; k < pre; k++ {
+		ops = append(ops, op{' ', a[k], k, k})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			ops = append(ops, op{' ', ma[i], pre + i, pre + j})
+			i, j = i+1, j+1
+		case j == len(mb) || (i < len(ma) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', ma[i], pre + i, pre + j})
+			i++
+		default:
+			ops = append(ops, op{'+', mb[j], pre + i, pre + j})
+			j++
+		}
+	}
+	for k := 0; k < suf; k++ {
+		ops = append(ops, op{' ', a[len(a)-suf+k], len(a) - suf + k, len(b) - suf + k})
+	}
+
+	var out strings.Builder
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		// extend the hunk while the changes are close enough to share
+		// their context
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				break
+			}
+			end = next
+		}
+		end += diffContext
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aname, bname)
+		}
+		var na, nb int
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				na++
+			}
+			if o.kind != '-' {
+				nb++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(ops[start].i, na), hunkRange(ops[start].j, nb))
+		for _, o := range ops[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", o.kind, o.line)
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the range of n lines starting at line index start.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
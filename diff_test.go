@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string // space separated lines
+		want string
+	}{{
+		name: "same",
+		a:    "1 2 3",
+		b:    "1 2 3",
+		want: ``,
+	}, {
+		name: "change",
+		a:    "1 2 3 4 5 6 7 8 9 10 11 12",
+		b:    "1 2 3 4 5 six 7 8 9 10 11 12",
+		want: `--- old
++++ new
+@@ -3,7 +3,7 @@
+ 3
+ 4
+ 5
+-6
++six
+ 7
+ 8
+ 9
+`,
+	}, {
+		name: "merged by context",
+		a:    "1 2 3 4 5 6 7 8 9 10 11 12 13 14 15",
+		b:    "1 2 three 4 5 6 7 8 9 ten 11 12 13 14 15",
+		want: `--- old
++++ new
+@@ -1,13 +1,13 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+ 7
+ 8
+ 9
+-10
++ten
+ 11
+ 12
+ 13
+`,
+	}, {
+		name: "adjacent hunks",
+		a:    "1 2 3 4 5 6 7 8 9 10 11 12 13 14 15",
+		b:    "1 2 three 4 5 6 7 8 9 10 eleven 12 13 14 15",
+		want: `--- old
++++ new
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -8,7 +8,7 @@
+ 8
+ 9
+ 10
+-11
++eleven
+ 12
+ 13
+ 14
+`,
+	}, {
+		name: "addition",
+		a:    "",
+		b:    "a b",
+		want: `--- old
++++ new
+@@ -0,0 +1,2 @@
++a
++b
+`,
+	}, {
+		name: "removal",
+		a:    "a b",
+		b:    "",
+		want: `--- old
++++ new
+@@ -1,2 +0,0 @@
+-a
+-b
+`,
+	}, {
+		name: "insertions around",
+		a:    "a b c d e",
+		b:    "x a b c d e y",
+		want: `--- old
++++ new
+@@ -1,5 +1,7 @@
++x
+ a
+ b
+ c
+ d
+ e
++y
+`,
+	}}
+	for _, tt := range tests {
+		got := unifiedDiff("old", "new", strings.Fields(tt.a), strings.Fields(tt.b))
+		if got != tt.want {
+			t.Errorf("%s: want\n%s\ngot\n%s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestHunkRange(t *testing.T) {
+	for _, tt := range []struct {
+		start, n int
+		want     string
+	}{
+		{0, 0, "0,0"},
+		{4, 0, "4,0"},
+		{0, 1, "1"},
+		{6, 1, "7"},
+		{0, 3, "1,3"},
+		{7, 7, "8,7"},
+	} {
+		if got := hunkRange(tt.start, tt.n); got != tt.want {
+			t.Errorf("hunkRange(%d, %d): want %q, got %q", tt.start, tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestUnifiedDiffLarge(t *testing.T) {
+	// without trimming the common lines the table would take gigabytes
+	var a, b []string
+	for i := 0; i < 20000; i++ {
+		a = append(a, fmt.Sprintf("line %d", i))
+	}
+	b = append(b, a...)
+	b[10000] = "changed"
+	got := unifiedDiff("old", "new", a, b)
+	if want := "@@ -9998,7 +9998,7 @@\n"; !strings.Contains(got, want) {
+		t.Errorf("want a single hunk %q, got\n%s", want, got)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// keepGoing makes commands handling several dependencies carry on after
+// one of them fails, and report all the failures at the end.
+var keepGoing bool
+
+// multiError collects the errors of several dependencies.
+type multiError []error
+
+func (e multiError) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = "\n\t" + err.Error()
+	}
+	return fmt.Sprintf("%d dependencies failed:%s", len(e), strings.Join(s, ""))
+}
+
+// The exit codes of gvt, see exitCode.
+const (
+	exitSuccess  = 0
+	exitFailure  = 1 // any other error
+	exitUsage    = 2 // bad flags or arguments
+	exitFetch    = 3 // a repository could not be fetched
+	exitMismatch = 4 // the vendor directory doesn't match the manifest
+	exitParse    = 5 // a Go file could not be parsed
+	exitManifest = 6 // the manifest could not be read or written
+)
+
+// exitError is an error causing gvt to exit with code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// usageErrorf returns an error about the flags or arguments of a command.
+func usageErrorf(format string, args ...interface{}) error {
+	return &exitError{exitUsage, fmt.Errorf(format, args...)}
+}
+
+// fetchError marks err as a failure to reach or check out a repository.
+func fetchError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{exitFetch, err}
+}
+
+// mismatchErrorf returns an error about differences between the vendor
+// directory and the manifest.
+func mismatchErrorf(format string, args ...interface{}) error {
+	return &exitError{exitMismatch, fmt.Errorf(format, args...)}
+}
+
+// exitCode returns the exit code for the error returned by a command. The
+// errors of several dependencies have the code they share, if any.
+func exitCode(err error) int {
+	if err == nil {
+		return exitSuccess
+	}
+	if me, ok := err.(multiError); ok && len(me) > 0 {
+		code := exitCode(me[0])
+		for _, err := range me[1:] {
+			if exitCode(err) != code {
+				return exitFailure
+			}
+		}
+		return code
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	var pe *vendor.ParseError
+	if errors.As(err, &pe) {
+		return exitParse
+	}
+	var me *vendor.ManifestError
+	if errors.As(err, &me) {
+		return exitManifest
+	}
+	if vendor.IsTemporary(err) {
+		return exitFetch
+	}
+	return exitFailure
+}
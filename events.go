@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var eventsFd int // file descriptor to write the events to, 0 for none
+
+// event is a line of the event stream.
+type event struct {
+	Event      string        `json:"event"`                // resolve, fetch-start, fetch-done, skip or error
+	Time       time.Time     `json:"time"`                 // when it happened
+	Importpath string        `json:"importpath,omitempty"` // the dependency concerned
+	Repository string        `json:"repository,omitempty"` // its repository, once resolved
+	Revision   string        `json:"revision,omitempty"`   // the revision fetched
+	Duration   time.Duration `json:"duration,omitempty"`   // of the fetch, in nanoseconds
+	Reason     string        `json:"reason,omitempty"`     // why it was skipped
+	Error      string        `json:"error,omitempty"`      // why it failed
+}
+
+// events serializes the events, so that each is a whole line even when
+// dependencies are fetched concurrently. It is safe for concurrent use.
+var events struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// setupEvents opens the file descriptor set with -events-fd.
+func setupEvents() error {
+	if eventsFd == 0 {
+		return nil
+	}
+	if eventsFd < 0 {
+		return fmt.Errorf("invalid -events-fd %d", eventsFd)
+	}
+	f := os.NewFile(uintptr(eventsFd), "events")
+	if _, err := f.Stat(); err != nil {
+		return fmt.Errorf("invalid -events-fd %d: %v", eventsFd, err)
+	}
+	events.w = f
+	return nil
+}
+
+// emit writes e to the event stream, if any.
+func emit(e event) {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	if events.w == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if _, err := events.w.Write(append(buf, '\n')); err != nil {
+		// don't fail the command because the reader went away
+		events.w = nil
+	}
+}
+
+// emitSkip emits a skip event for importpath.
+func emitSkip(importpath, reason string) {
+	emit(event{Event: "skip", Importpath: importpath, Reason: reason})
+}
+
+// emitError emits an error event for importpath and returns err.
+func emitError(importpath string, err error) error {
+	emit(event{Event: "error", Importpath: importpath, Error: err.Error()})
+	return err
+}
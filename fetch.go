@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/url"
+	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
+	"time"
 
 	"go/build"
 
@@ -15,13 +20,20 @@ import (
 )
 
 var (
-	branch    string
-	revision  string // revision (commit)
-	tag       string
-	noRecurse bool
-	insecure  bool // Allow the use of insecure protocols
+	branch     string
+	revision   string // revision (commit)
+	tag        string
+	noRecurse  bool
+	insecure   bool // Allow the use of insecure protocols
+	tests      bool // fetch the dependencies of tests as well
+	maxDepth   int  // maximum depth of recursive dependencies, 0 for no limit
+	pruneFiles bool // remove files not needed to build the packages
 
 	recurse bool // should we fetch recursively
+
+	importList string // file listing the import paths to fetch, if any
+
+	pins = make(pinFlag) // revisions of recursive dependencies
 )
 
 func addFetchFlags(fs *flag.FlagSet) {
@@ -29,24 +41,178 @@ func addFetchFlags(fs *flag.FlagSet) {
 	fs.StringVar(&revision, "revision", "", "revision of the package")
 	fs.StringVar(&tag, "tag", "", "tag of the package")
 	fs.BoolVar(&noRecurse, "no-recurse", false, "do not fetch recursively")
+	fs.StringVar(&importList, "import-list", "", "file listing the import paths to fetch")
 	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.Var(insecureHostsFlag{}, "insecure-host", "host for which insecure protocols are allowed")
+	fs.BoolVar(&tests, "tests", false, "fetch the dependencies of tests")
+	fs.IntVar(&maxDepth, "max-depth", 0, "maximum depth of recursive dependencies")
+	fs.Var(pins, "pin", "revision of a recursive dependency, as importpath=revision")
+	fs.Var(&ignored, "ignore", "pattern of import paths not to fetch recursively")
+	fs.Var(providedFlag{}, "provided", "prefix of import paths provided by the platform")
+	fs.Var(platformsFlag{}, "platforms", "comma separated GOOS/GOARCH pairs to consider")
+	fs.Var(replacements, "replace", "local directory of a dependency, as importpath=dir")
+	fs.BoolVar(&replaceSymlink, "replace-symlink", false, "symlink replaced dependencies instead of copying them")
+	fs.StringVar(&self, "self", "", "import path of the project, never fetched")
+	fs.BoolVar(&summaryAsJSON, "json", false, "print the summary as JSON")
+	fs.BoolVar(&pruneFiles, "prune-files", false, "remove test files, testdata and documentation")
+	fs.BoolVar(&flatten, "flatten", false, "move nested vendor directories to the top-level one")
+	fs.BoolVar(&minimal, "minimal", false, "only keep the packages the project imports")
+	fs.BoolVar(&vendor.ShallowClone, "shallow", false, "only clone the last commit of git repositories")
+	fs.Var(&allowedLicenses, "allow", "SPDX identifiers of the only licenses allowed")
+	fs.Var(&deniedLicenses, "deny", "SPDX identifiers of licenses not allowed")
+	addNetworkFlags(fs)
+	fs.IntVar(&eventsFd, "events-fd", 0, "file descriptor to write JSON events to")
 }
 
 var cmdFetch = &Command{
 	Name:      "fetch",
-	UsageLine: "fetch [-branch branch | -revision rev | -tag tag] [-precaire] [-no-recurse] importpath",
+	UsageLine: "fetch [-branch branch | -revision rev | -tag tag] [-precaire] [-insecure-host host] [-no-recurse] [-max-depth n] [-pin importpath=rev] [-ignore pattern] [-provided prefix] [-platforms list] [-replace importpath=dir] [-replace-symlink] [-self importpath] [-tests] [-prune-files] [-flatten] [-minimal] [-shallow] [-allow licenses] [-deny licenses] [-json] importpath | -import-list file",
 	Short:     "fetch a remote dependency",
 	Long: `fetch vendors an upstream import path.
 
 The import path may include a url scheme. This may be useful when fetching dependencies
 from private repositories that cannot be probed.
 
+gopkg.in import paths are fetched from the GitHub repository behind them, at
+the branch or tag with the highest version matching the one in the path, as
+gopkg.in would serve it. The manifest records that repository, and the
+version, as in "v2", as the branch so that update follows it.
+
+Missing dependencies are then fetched recursively. Each of them is vendored
+from the root of its repository, so that packages sharing a repository are
+fetched once and recorded in a single manifest entry. The manifest records
+them as transitive. Fetching one of them again records it as direct instead.
+Imports which are not valid import paths, as the go command would reject
+them, like those of templated files, are skipped with a warning.
+
+A dependency in the manifest whose directory is missing from the vendor
+directory, as after an interrupted run, is not considered vendored: it is
+fetched again at the revision the manifest records.
+
+Afterwards, a warning is printed for each dependency that nothing in the
+project imports, directly or through other dependencies, for review. The
+fetched import path is exempt, as it might not be imported yet. Nothing is
+deleted, see prune.
+
+A warning is also printed for each vendored package whose import comment,
+as in package foo // import "example.com/foo", declares another canonical
+import path, as building against it through the vendored one breaks.
+
+Private repositories over HTTPS can be accessed with the credentials of a
+netrc file, see -netrc, or with a token in an environment variable named
+after the host, as in GVT_TOKEN_GITHUB_COM for github.com. The token may be
+prefixed with a login and a colon. Credentials are never written to the
+manifest or printed.
+
+Metadata requests and vcs commands go through the proxies set in the
+HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables. This is
+independent of -precaire, which only allows insecure protocols.
+
+Metadata requests rate limited by the host, with a 429 status or a 403 with
+a Retry-After header, are retried up to 3 times after waiting as long as
+the host asks, if that is at most a minute.
+
 Flags:
 	-branch branch
 		fetch from the name branch. If not supplied the default upstream
 		branch will be used.
 	-no-recurse
 		do not fetch recursively.
+	-import-list file
+		fetch the import paths listed in file, one per line, instead of
+		a single one, as produced by another tool. Each is vendored from
+		the root of its repository, like recursive dependencies. Blank
+		lines, lines starting with # and import paths which don't need
+		to be fetched, like those of the standard library, are skipped,
+		as are those already vendored. Malformed import paths are logged
+		and skipped. It can't be used with -branch, -tag or -revision,
+		which would apply to every repository.
+	-max-depth n
+		only fetch recursive dependencies up to n imports away from the
+		fetched package. Deeper imports are logged and left missing.
+		If not supplied there is no limit.
+	-pin importpath=rev
+		fetch the recursive dependency with the given repository root import
+		path at revision rev, which may also be a tag, instead of at the head
+		of the default branch. Can be supplied multiple times.
+	-ignore pattern
+		do not fetch recursive dependencies whose import path, or one of
+		its parents, matches pattern, as in "example.com/internal/*".
+		Can be supplied multiple times. Patterns are also read, one per
+		line, from a .gvtignore file in the project directory.
+	-provided prefix
+		never fetch the imports which are or are inside prefix, because
+		the platform the project runs on provides them. The App Engine
+		SDK imports "appengine" and "appengine_internal" are always
+		provided. Can be supplied multiple times.
+	-platforms list
+		evaluate the build constraints of each file for all the comma
+		separated GOOS/GOARCH pairs, as in "linux/amd64,windows/amd64",
+		and use the file if any of them matches, so that the imports
+		needed by every platform are fetched. Can be supplied multiple times.
+		If not supplied only the host platform is considered.
+	-replace importpath=dir
+		vendor the dependency with the given repository root import path,
+		fetched directly or recursively, by copying the local directory
+		dir instead of fetching it, for local development. dir may be
+		relative to the project directory. The manifest records dir
+		instead of a repository and revision, verify and status report
+		the dependency as "replaced", and update and rebuild copy dir
+		again. Can be supplied multiple times. Replacements are also read,
+		one per line, from a .gvtreplace file in the project directory.
+	-replace-symlink
+		symlink the vendored copy of the replaced dependencies to their
+		local directory instead of copying it, so that changes show up
+		without copying again.
+	-self importpath
+		the import path of the project. Its packages, including the internal
+		ones, are never fetched even if a dependency imports them, which
+		is reported along with the chain of imports leading to them. If not
+		supplied it is deduced from the location of the project in GOPATH.
+	-tests
+		also fetch the dependencies of the package tests, including
+		external test packages. Only the tests of the fetched package
+		are considered, not those of its dependencies. Dependencies only
+		the tests need are marked as such in the manifest.
+	-shallow
+		only clone the last commit of git repositories, which is faster
+		for large ones. Repositories fetched at a -revision or -pin, and
+		other VCS, are still cloned in full. Recorded in the manifest.
+	-prune-files
+		remove test files, testdata directories, CI configuration and
+		markdown documentation from the fetched dependencies. License,
+		copying and notice files are always kept. This is recorded in the
+		manifest, so that update and rebuild prune them the same way.
+		Can't be used with -tests.
+	-flatten
+		move the packages in the vendor directories of the fetched
+		dependencies to the top-level vendor directory, recording them
+		in the manifest as hoisted. A package already vendored at a
+		different version is reported and left nested. This is
+		recorded in the manifest, so that update and rebuild flatten
+		them the same way.
+	-minimal
+		once all the dependencies are fetched, only keep the packages
+		of each that the project imports, even through other
+		dependencies, along with their directories without Go files
+		and the license files. A dependency none of whose packages is
+		imported yet is kept whole. The kept packages are recorded in
+		the manifest: rebuild keeps the same, update computes them again.
+		Fetching a package which was not kept, directly or recursively,
+		copies it back at the recorded revision and records it as kept.
+	-allow licenses
+		only vendor dependencies whose license, as detected by the license
+		command, is one of the comma separated SPDX identifiers. Use
+		"none" and "unknown" to allow dependencies without a license file
+		or with an unrecognized one. Can be supplied multiple times.
+	-deny licenses
+		never vendor dependencies with one of the comma separated licenses,
+		as in "GPL-2.0,GPL-3.0,AGPL-3.0". Can be supplied multiple times.
+	-json
+		print the summary of the fetch, the number of dependencies
+		vendored and of those needed but already vendored, the bytes
+		written and the duration in nanoseconds, as a JSON object on the
+		standard output. Otherwise it is logged.
 	-tag tag
 		fetch the specified tag. If not supplied the default upstream
 		branch will be used.
@@ -55,56 +221,161 @@ Flags:
 		revision supplied, the latest available will be supplied.
 	-precaire
 		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: resolve, fetch-start, fetch-done, skip and error.
 
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
 `,
-	Run: func(args []string) error {
-		switch len(args) {
-		case 0:
-			return fmt.Errorf("fetch: import path missing")
-		case 1:
-			path := args[0]
-			recurse = !noRecurse
-			return fetch(path, recurse)
-		default:
-			return fmt.Errorf("more than one import path supplied")
+	Run: func(ctx context.Context, args []string) error {
+		switch {
+		case len(args) == 0 && importList == "":
+			return usageErrorf("fetch: import path missing")
+		case len(args) > 0 && importList != "":
+			return usageErrorf("fetch: -import-list can't be used with an import path")
+		case importList != "" && (branch != "" || tag != "" || revision != ""):
+			return usageErrorf("fetch: -import-list can't be used with -branch, -tag or -revision")
+		case len(args) > 1:
+			return usageErrorf("more than one import path supplied")
+		}
+		recurse = !noRecurse
+		if pruneFiles && tests {
+			return usageErrorf("fetch: -prune-files removes the test files -tests would parse")
+		}
+		if err := loadIgnoreFile(); err != nil {
+			return fmt.Errorf("could not load %s: %v", ignorefile, err)
+		}
+		if err := checkIgnored(); err != nil {
+			return err
 		}
+		if err := loadReplaceFile(); err != nil {
+			return fmt.Errorf("could not load %s: %v", replacefile, err)
+		}
+		if importList != "" {
+			return fetchImportList(ctx, importList)
+		}
+		path := args[0]
+		if isSelf(path) {
+			return fmt.Errorf("fetch: %s is part of the project", path)
+		}
+		summary.Start()
+		if err := fetch(ctx, path, recurse, false, false); err != nil {
+			return err
+		}
+		if err := minimizeFetched(); err != nil {
+			return err
+		}
+		warnUnused(stripscheme(path))
+		return summary.Print()
 	},
 	AddFlags: addFetchFlags,
+	Locks:    true,
 }
 
-func fetch(path string, recurse bool) error {
+// fetch vendors path and, if recurse is set, its missing dependencies.
+// If wholeRepo is set, the root of the repository containing path is
+// vendored instead of just path. If transitive is set, path is recorded as
+// needed by another dependency rather than asked for.
+func fetch(ctx context.Context, path string, recurse, wholeRepo, transitive bool) error {
 	m, err := vendor.ReadManifest(manifestFile())
 	if err != nil {
-		return fmt.Errorf("could not load manifest: %v", err)
+		return fmt.Errorf("could not load manifest: %w", err)
 	}
 
-	repo, extra, err := vendor.DeduceRemoteRepo(path, insecure)
+	if root, dir, ok := replacement(stripscheme(path)); ok {
+		if err := fetchReplacement(m, root, dir, transitive); err != nil {
+			return err
+		}
+		if !recurse {
+			return nil
+		}
+		return fetchMissing(ctx, root)
+	}
+
+	repo, extra, err := vendor.DeduceRemoteRepoContext(ctx, path, insecure)
 	if err != nil {
-		return err
+		return emitError(stripscheme(path), fetchError(err))
 	}
 
 	// strip of any scheme portion from the path, it is already
 	// encoded in the repo.
 	path = stripscheme(path)
+	pkg := path
+
+	if wholeRepo {
+		// keep a major version suffix, it may be part of the import path
+		major, _ := vendor.SplitMajorVersion(extra)
+		path = path[:len(path)-len(extra)] + major
+		extra = major
+	}
+	emit(event{Event: "resolve", Importpath: path, Repository: repo.URL()})
 
+	restored, err := restoreMissing(ctx, m, path)
+	if err != nil {
+		return err
+	}
+	if d, err := m.GetDependencyForImportpath(path); err == nil && d.Transitive && !transitive {
+		// asking for a dependency fetched recursively makes it direct
+		m.RemoveDependency(d)
+		d.Transitive = false
+		if err := m.AddDependency(d); err != nil {
+			return err
+		}
+		log.Printf("%s is already vendored, recording it as a direct dependency", path)
+		summary.Skip(path)
+		return vendor.WriteManifest(manifestFile(), m)
+	}
+	if restored {
+		return nil
+	}
+	if dep, ok := prunedDependency(m, pkg); ok {
+		if err := restorePackage(ctx, m, dep, pkg); err != nil {
+			return err
+		}
+		if !recurse {
+			return nil
+		}
+		return fetchMissing(ctx, dep.Importpath)
+	}
 	if m.HasImportpath(path) {
 		return fmt.Errorf("%s is already vendored", path)
 	}
+	if c, ok := caseCollision(m, path); ok && caseInsensitive {
+		return fmt.Errorf("%s would overwrite %s on a case-insensitive file system", path, c)
+	}
+
+	rev := revision
+	if branch == "" && tag == "" && revision == "" {
+		if pin, ok := pins[path]; ok {
+			debugf("%s is pinned to %s", path, pin)
+			rev = pin
+		}
+	}
 
-	wc, err := repo.Checkout(branch, tag, revision)
+	debugf("fetching %s from %s", path, repo.URL())
+	start := time.Now()
+	emit(event{Event: "fetch-start", Importpath: path, Repository: repo.URL()})
+
+	wc, err := checkout(ctx, repo, branch, tag, rev)
 
 	if err != nil {
-		return err
+		return emitError(path, err)
 	}
 
-	rev, err := wc.Revision()
+	rev, err = wc.Revision()
 	if err != nil {
-		return err
+		return emitError(path, err)
 	}
 
+	extra = majorVersionPath(wc, extra)
+
 	branch, err := wc.Branch()
 	if err != nil {
-		return err
+		return emitError(path, err)
 	}
 
 	dep := vendor.Dependency{
@@ -113,16 +384,50 @@ func fetch(path string, recurse bool) error {
 		Revision:   rev,
 		Branch:     branch,
 		Path:       extra,
+		Pruned:     pruneFiles,
+		Shallow:    isShallow(wc),
+		Transitive: transitive,
+		Flattened:  flatten,
+		FetchedAt:  now(),
 	}
 
-	if err := m.AddDependency(dep); err != nil {
-		return err
+	dst := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
+	src := filepath.Join(wc.Dir(), filepath.FromSlash(dep.Path))
+
+	// the license file is often at the root of the repository
+	if err := checkLicense(dep.Importpath, src, wc.Dir()); err != nil {
+		wc.Destroy()
+		return emitError(path, err)
 	}
 
-	dst := filepath.Join(vendorDir(), dep.Importpath)
-	src := filepath.Join(wc.Dir(), dep.Path)
+	if dep.Pruned {
+		if err := vendor.PruneFiles(src); err != nil {
+			return emitError(path, err)
+		}
+	}
 
 	if err := vendor.Copypath(dst, src); err != nil {
+		return emitError(path, err)
+	}
+	warnNonCanonical(dep.Importpath, dst)
+	if dep.Flattened {
+		if err := flattenVendor(m, dep); err != nil {
+			return emitError(path, err)
+		}
+	}
+	if err := summary.Add(dep.Importpath, dst); err != nil {
+		return err
+	}
+	if minimal {
+		minimized = append(minimized, dep.Importpath)
+	}
+
+	dep.Checksum, err = checksum(m, dep.Importpath)
+	if err != nil {
+		return err
+	}
+
+	if err := m.AddDependency(dep); err != nil {
 		return err
 	}
 
@@ -133,18 +438,203 @@ func fetch(path string, recurse bool) error {
 	if err := wc.Destroy(); err != nil {
 		return err
 	}
+	emit(event{Event: "fetch-done", Importpath: path, Repository: dep.Repository,
+		Revision: dep.Revision, Duration: time.Since(start)})
 
 	if !recurse {
 		return nil
 	}
+	return fetchMissing(ctx, path)
+}
+
+// missingDependency returns the dependency of m holding path if its
+// directory is missing from the vendor directory, as after an interrupted
+// run, so that the manifest alone makes path look vendored. Hoisted
+// dependencies are only restored with the one they were hoisted from.
+func missingDependency(m *vendor.Manifest, path string) (vendor.Dependency, bool) {
+	root := innermostDependency(m, path)
+	if root == "" {
+		return vendor.Dependency{}, false
+	}
+	dep, err := m.GetDependencyForImportpath(root)
+	if err != nil || dep.Hoisted != "" {
+		return vendor.Dependency{}, false
+	}
+	dir := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return vendor.Dependency{}, false
+	}
+	return dep, true
+}
+
+// restoreMissing fetches again at its recorded revision the dependency of
+// m holding path, if it is missing from the vendor directory, and reports
+// whether it did.
+func restoreMissing(ctx context.Context, m *vendor.Manifest, path string) (bool, error) {
+	dep, ok := missingDependency(m, path)
+	if !ok {
+		return false, nil
+	}
+	log.Printf("%s is in the manifest but missing from the vendor directory, fetching it again", dep.Importpath)
+	start := time.Now()
+	emit(event{Event: "fetch-start", Importpath: dep.Importpath, Repository: dep.Repository})
+	shared := newCheckouts([]vendor.Dependency{dep})
+	defer shared.Close()
+	err := rebuildDependency(ctx, dep, shared, insecure)
+	if err == nil && dep.Flattened {
+		err = placeHoisted(m, dep)
+	}
+	if err == nil && len(dep.Packages) > 0 {
+		err = prunePackages(m, dep)
+	}
+	if err != nil {
+		return false, emitError(dep.Importpath, fmt.Errorf("could not restore %s: %v", dep.Importpath, err))
+	}
+	if err := summary.Add(dep.Importpath, filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))); err != nil {
+		return false, err
+	}
+	emit(event{Event: "fetch-done", Importpath: dep.Importpath, Repository: dep.Repository,
+		Revision: dep.Revision, Duration: time.Since(start)})
+	return true, nil
+}
+
+// warnNonCanonical warns about the packages of the dependency importpath,
+// vendored in dir, whose import comment declares another import path, as
+// importing them through the vendored one would break the build.
+func warnNonCanonical(importpath, dir string) {
+	paths, err := vendor.NonCanonicalImports(dir, filepath.FromSlash(importpath))
+	if err != nil {
+		debugf("not checking the import comments of %s: %v", importpath, err)
+		return
+	}
+	var sorted []string
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+	for _, path := range sorted {
+		warnf("%s declares %s as its canonical import path, import and fetch that instead", path, paths[path])
+	}
+}
+
+// fetchImportList vendors the repositories of the import paths listed in
+// file, one per line, and recursively their dependencies if recurse is set.
+// Blank lines, lines starting with # and import paths which don't need to
+// be fetched, like those of the standard library, are skipped.
+func fetchImportList(ctx context.Context, file string) error {
+	paths, err := readImportList(file)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %v", file, err)
+	}
+	summary.Start()
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := vendor.CheckImportPath(path); err != nil {
+			log.Printf("skipping %v", err)
+			continue
+		}
+		remote, err := vendor.IsRemoteImport(path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !remote:
+			debugf("skipping %s, it doesn't need to be fetched", path)
+			continue
+		case isSelf(path):
+			debugf("skipping %s, it is part of the project", path)
+			continue
+		case isIgnored(path):
+			log.Printf("ignoring %s", path)
+			continue
+		}
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %w", err)
+		}
+		_, missing := missingDependency(m, path)
+		_, pruned := prunedDependency(m, path)
+		if m.HasImportpath(path) && !missing && !pruned {
+			debugf("%s is already vendored, skipping", path)
+			summary.Skip(innermostDependency(m, path))
+			continue
+		}
+		log.Printf("fetching %s", path)
+		if err := fetch(ctx, path, recurse, true, false); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := minimizeFetched(); err != nil {
+		return err
+	}
+	return summary.Print()
+}
+
+// readImportList returns the import paths listed in file, one per line,
+// skipping blank lines and lines starting with #.
+func readImportList(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var paths []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, sc.Err()
+}
+
+// warnUnused warns about the dependencies which nothing in the project
+// imports, even through other dependencies, with the exception of the
+// newly fetched path, which might not be imported yet, and the
+// dependencies it imports. Nothing is removed, see prune.
+func warnUnused(path string) {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return
+	}
+	used, err := usedImports(m, true, path)
+	if err != nil {
+		debugf("not checking for unused dependencies: %v", err)
+		return
+	}
+	for _, dep := range m.Dependencies {
+		if !isUsed(used, dep.Importpath) {
+			warnf("%s is not imported by the project, see prune", dep.Importpath)
+		}
+	}
+}
 
+// fetchMissing fetches recursively the missing dependencies of the
+// vendored import path path.
+func fetchMissing(ctx context.Context, path string) error {
 	// if we are recursing, overwrite branch, tag and revision
 	// values so recursive fetching checks out from HEAD.
 	branch = ""
 	tag = ""
 	revision = ""
 
+	// attempted records the recursive dependencies already fetched, so
+	// that one which is still missing afterwards is skipped instead of
+	// being fetched again.
+	attempted := make(map[string]bool)
+	skipped := make(map[string]bool)
+
+	p := startProgress()
+	defer p.Stop()
+
 	for done := false; !done; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		paths := []struct {
 			Root, Prefix string
@@ -164,15 +654,89 @@ func fetch(path string, recurse bool) error {
 			return err
 		}
 
-		is, ok := dsm[filepath.Join(vendorDir(), path)]
+		is, ok := dsm[filepath.Join(vendorDir(), filepath.FromSlash(path))]
 		if !ok {
 			return fmt.Errorf("unable to locate depset for %q", path)
 		}
 
-		missing := findMissing(pkgs(is.Pkgs), dsm)
+		missing, cut := findMissing(pkgs(is.Pkgs), dsm, tests, maxDepth)
+		for pkg := range cut {
+			if !missing[pkg] && !skipped[pkg] {
+				warnf("not following %s, it is part of an import loop or deeper than -max-depth", pkg)
+				emitSkip(pkg, "import loop or -max-depth")
+				skipped[pkg] = true
+			}
+		}
+		for pkg := range missing {
+			if err := vendor.CheckImportPath(pkg); err != nil {
+				delete(missing, pkg)
+				if !skipped[pkg] {
+					warnf("not fetching %v", err)
+					emitSkip(pkg, "invalid import path")
+					skipped[pkg] = true
+				}
+				continue
+			}
+			if c, ok := caseCollision(m, pkg); ok && caseInsensitive {
+				delete(missing, pkg)
+				if !skipped[pkg] {
+					warnf("not fetching %s, it differs from %s only by case", pkg, c)
+					emitSkip(pkg, "case collision with "+c)
+					skipped[pkg] = true
+				}
+				continue
+			}
+			if isSelf(pkg) {
+				// a dependency importing the project, as forks sometimes
+				// do, would have the project vendored inside itself
+				delete(missing, pkg)
+				if !skipped[pkg] {
+					chain := importChain(pkgs(is.Pkgs), dsm, pkg)
+					warnf("not fetching %s, it is part of the project but imported by a dependency: %s", pkg, strings.Join(chain, " -> "))
+					emitSkip(pkg, "part of the project")
+					skipped[pkg] = true
+				}
+				continue
+			}
+			if vendor.IsProvided(pkg) {
+				delete(missing, pkg)
+				if !skipped[pkg] {
+					debugf("not fetching %s, it is provided by the platform", pkg)
+					emitSkip(pkg, "provided")
+					skipped[pkg] = true
+				}
+				continue
+			}
+			if isIgnored(pkg) {
+				delete(missing, pkg)
+				if !skipped[pkg] {
+					log.Printf("ignoring %s", pkg)
+					emitSkip(pkg, "ignored")
+					skipped[pkg] = true
+				}
+				continue
+			}
+			if !attempted[pkg] {
+				continue
+			}
+			delete(missing, pkg)
+			if !skipped[pkg] {
+				warnf("%s is still missing after fetching it, skipping", pkg)
+				emitSkip(pkg, "still missing after fetching")
+				skipped[pkg] = true
+			}
+		}
 		switch len(missing) {
 		case 0:
 			done = true
+			for n := range importedFrom(pkgs(is.Pkgs), dsm, tests) {
+				if d, ok := dependencyOf(m, n); ok && d != path {
+					summary.Skip(d)
+				}
+			}
+			if err := markTestOnly(m, pkgs(is.Pkgs), dsm, attempted); err != nil {
+				return err
+			}
 		default:
 
 			// sort keys in ascending order, so the shortest missing import path
@@ -180,8 +744,12 @@ func fetch(path string, recurse bool) error {
 			keys := keys(missing)
 			sort.Strings(keys)
 			pkg := keys[0]
+			p.Set("fetched %d/%d, fetching %s", len(attempted), len(attempted)+len(missing), pkg)
+			attempted[pkg] = true
+			// fetch the whole repository, so that other packages from it
+			// don't need their own checkout and manifest entry.
 			log.Printf("fetching recursive dependency %s", pkg)
-			if err := fetch(pkg, false); err != nil {
+			if err := fetch(ctx, pkg, false, true, true); err != nil {
 				return err
 			}
 		}
@@ -190,6 +758,17 @@ func fetch(path string, recurse bool) error {
 	return nil
 }
 
+// now returns the current time as recorded in the manifest.
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// isShallow reports whether wc was checked out without its history.
+func isShallow(wc vendor.WorkingCopy) bool {
+	s, ok := wc.(interface{ Shallow() bool })
+	return ok && s.Shallow()
+}
+
 func keys(m map[string]bool) []string {
 	var s []string
 	for k := range m {
@@ -206,14 +785,136 @@ func pkgs(m map[string]*vendor.Pkg) []*vendor.Pkg {
 	return p
 }
 
-func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset) map[string]bool {
-	missing := make(map[string]bool)
+// markTestOnly records as Test the dependencies of m holding the attempted
+// packages when they are not reachable from pkgs without going through the
+// imports of tests. It clears Test for the dependencies which are, as one
+// first fetched for the tests of a package may be imported by another, and
+// writes the manifest if any changed.
+func markTestOnly(m *vendor.Manifest, pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset, attempted map[string]bool) error {
+	neededDeps := make(map[string]bool)
+	for n := range importedFrom(pkgs, dsm, false) {
+		if d, ok := dependencyOf(m, n); ok {
+			neededDeps[d] = true
+		}
+	}
+
+	var changed bool
+	for i, d := range m.Dependencies {
+		if d.Test && neededDeps[d.Importpath] {
+			debugf("%s is not only needed by tests anymore", d.Importpath)
+			m.Dependencies[i].Test = false
+			changed = true
+		}
+	}
+	for pkg := range attempted {
+		d, ok := dependencyOf(m, pkg)
+		if !ok || neededDeps[d] {
+			continue
+		}
+		for i := range m.Dependencies {
+			if m.Dependencies[i].Importpath == d && !m.Dependencies[i].Test {
+				debugf("%s is only needed by tests", d)
+				m.Dependencies[i].Test = true
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return vendor.WriteManifest(manifestFile(), m)
+}
+
+// importedFrom returns pkgs and the import paths they import, directly or
+// through the packages of dsm, including the imports of the tests of pkgs
+// if tests is set.
+func importedFrom(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset, tests bool) map[string]bool {
+	imports := importMap(dsm)
+	seen := make(map[string]bool)
+	var queue []string
+	for _, p := range pkgs {
+		queue = append(queue, p.ImportPath)
+		if tests {
+			queue = append(queue, p.TestImports...)
+			queue = append(queue, p.XTestImports...)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		if p, ok := imports[n]; ok {
+			queue = append(queue, p.Imports...)
+		}
+	}
+	return seen
+}
+
+// importMap returns the packages of dsm by import path.
+func importMap(dsm map[string]*vendor.Depset) map[string]*vendor.Pkg {
 	imports := make(map[string]*vendor.Pkg)
 	for _, s := range dsm {
 		for _, p := range s.Pkgs {
 			imports[p.ImportPath] = p
 		}
 	}
+	return imports
+}
+
+// importChain returns the shortest chain of imports from one of pkgs to
+// target, through the packages of dsm, ending with target.
+func importChain(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset, target string) []string {
+	imports := importMap(dsm)
+
+	// prev points each package reached to the one importing it
+	prev := make(map[string]string)
+	var queue []string
+	for _, p := range pkgs {
+		prev[p.ImportPath] = ""
+		queue = append(queue, p.ImportPath)
+	}
+	sort.Strings(queue)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n == target {
+			break
+		}
+		p, ok := imports[n]
+		if !ok {
+			continue
+		}
+		for _, i := range p.Imports {
+			if _, ok := prev[i]; !ok {
+				prev[i] = n
+				queue = append(queue, i)
+			}
+		}
+	}
+	if _, ok := prev[target]; !ok {
+		return []string{target}
+	}
+
+	var chain []string
+	for n := target; n != ""; n = prev[n] {
+		chain = append([]string{n}, chain...)
+	}
+	return chain
+}
+
+// findMissing returns the import paths reachable from pkgs that are not
+// present in dsm. If tests is true the test and external test imports of
+// pkgs are followed as well. If maxDepth is not 0, missing imports more
+// than maxDepth steps away from pkgs are not reported as missing. Those,
+// and the import paths that were not followed because of an import loop,
+// are returned in cut.
+func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset, tests bool, maxDepth int) (missing, cut map[string]bool) {
+	missing = make(map[string]bool)
+	cut = make(map[string]bool)
+	imports := importMap(dsm)
 
 	// make fake C package for cgo
 	imports["C"] = &vendor.Pkg{
@@ -224,9 +925,6 @@ func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset) map[string]b
 	}
 	stk := make(map[string]bool)
 	push := func(v string) {
-		if stk[v] {
-			panic(fmt.Sprintln("import loop:", v, stk))
-		}
 		stk[v] = true
 	}
 	pop := func(v string) {
@@ -239,10 +937,14 @@ func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset) map[string]b
 	// checked records import paths who's dependencies are all present
 	checked := make(map[string]bool)
 
-	var fn func(string)
-	fn = func(importpath string) {
+	var fn func(string, int)
+	fn = func(importpath string, depth int) {
 		p, ok := imports[importpath]
 		if !ok {
+			if maxDepth > 0 && depth > maxDepth {
+				cut[importpath] = true
+				return
+			}
 			missing[importpath] = true
 			return
 		}
@@ -252,26 +954,190 @@ func findMissing(pkgs []*vendor.Pkg, dsm map[string]*vendor.Depset) map[string]b
 			return
 		}
 
-		sz := len(missing)
+		if stk[importpath] {
+			cut[importpath] = true
+			return
+		}
+
+		// arms that were cut short are not complete either
+		sz, c := len(missing), len(cut)
 		push(importpath)
 		for _, i := range p.Imports {
 			if i == importpath {
 				continue
 			}
-			fn(i)
+			fn(i, depth+1)
 		}
 
 		// if the size of the missing map has not changed
 		// this entire subtree is complete, mark it as such
-		if len(missing) == sz {
+		if len(missing) == sz && len(cut) == c {
 			checked[importpath] = true
 		}
 		pop(importpath)
 	}
 	for _, pkg := range pkgs {
-		fn(pkg.ImportPath)
+		fn(pkg.ImportPath, 0)
+		if !tests {
+			continue
+		}
+		for _, imports := range [][]string{pkg.TestImports, pkg.XTestImports} {
+			for _, i := range imports {
+				if i == pkg.ImportPath {
+					continue
+				}
+				fn(i, 1)
+			}
+		}
+	}
+	return missing, cut
+}
+
+// pinFlag is a flag.Value collecting importpath=revision pairs.
+type pinFlag map[string]string
+
+func (p pinFlag) String() string {
+	var s []string
+	for path, rev := range p {
+		s = append(s, path+"="+rev)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (p pinFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("expected importpath=revision, got %q", value)
+	}
+	p[value[:i]] = value[i+1:]
+	return nil
+}
+
+// insecureHostsFlag is a flag.Value adding each host to vendor.InsecureHosts.
+type insecureHostsFlag struct{}
+
+func (insecureHostsFlag) String() string {
+	s := keys(vendor.InsecureHosts)
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (insecureHostsFlag) Set(host string) error {
+	vendor.InsecureHosts[host] = true
+	return nil
+}
+
+// mirrorFlag is a flag.Value adding each prefix=mirror rule to
+// vendor.Mirrors.
+type mirrorFlag struct{}
+
+func (mirrorFlag) String() string {
+	var s []string
+	for prefix, mirror := range vendor.Mirrors {
+		s = append(s, prefix+"="+mirror)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (mirrorFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("expected prefix=mirror, got %q", value)
+	}
+	prefix, mirror := strings.Trim(value[:i], "/"), strings.TrimRight(value[i+1:], "/")
+	if strings.Contains(prefix, "://") || strings.Contains(mirror, "://") {
+		return fmt.Errorf("%q: the prefix and mirror must not include a scheme", value)
+	}
+	vendor.Mirrors[prefix] = mirror
+	return nil
+}
+
+// vcsFlag sets vendor.VCS from values like "launchpad.net=git", or "hg"
+// for all import paths.
+type vcsFlag struct{}
+
+func (vcsFlag) String() string {
+	var s []string
+	for prefix, vcs := range vendor.VCS {
+		if prefix == "" {
+			s = append(s, vcs)
+		} else {
+			s = append(s, prefix+"="+vcs)
+		}
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (vcsFlag) Set(value string) error {
+	var prefix, vcs string
+	if i := strings.LastIndex(value, "="); i >= 0 {
+		prefix, vcs = strings.Trim(value[:i], "/"), value[i+1:]
+		if prefix == "" {
+			return fmt.Errorf("expected [prefix=]vcs, got %q", value)
+		}
+	} else {
+		vcs = value
+	}
+	switch vcs {
+	case "git", "hg", "bzr", "svn":
+	default:
+		return fmt.Errorf("%q: unknown version control system %q, expected git, hg, bzr or svn", value, vcs)
+	}
+	vendor.VCS[prefix] = vcs
+	return nil
+}
+
+// checksum returns the checksum of the vendored copy of importpath,
+// leaving out the other dependencies in m which are nested inside it.
+func checksum(m *vendor.Manifest, importpath string) (string, error) {
+	dir := filepath.Join(vendorDir(), filepath.FromSlash(importpath))
+	var skip []string
+	for _, d := range m.Dependencies {
+		if strings.HasPrefix(d.Importpath, importpath+"/") {
+			skip = append(skip, filepath.Join(vendorDir(), filepath.FromSlash(d.Importpath)))
+		}
+	}
+	return vendor.TreeChecksum(dir, skip...)
+}
+
+// caseInsensitive is true on the platforms whose file systems usually don't
+// tell apart import paths differing only by case.
+var caseInsensitive = runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+
+// caseCollision returns the import path of the dependency of m that path
+// would be vendored in, or over, if case was ignored, when it is not already
+// part of it.
+func caseCollision(m *vendor.Manifest, path string) (string, bool) {
+	lower := strings.ToLower(path)
+	for _, d := range m.Dependencies {
+		if path == d.Importpath || strings.HasPrefix(path, d.Importpath+"/") {
+			continue
+		}
+		dep := strings.ToLower(d.Importpath)
+		if lower == dep || strings.HasPrefix(lower, dep+"/") || strings.HasPrefix(dep, lower+"/") {
+			return d.Importpath, true
+		}
+	}
+	return "", false
+}
+
+// majorVersionPath returns the path inside the working copy of the package
+// at extra. A major version suffix at the start of extra, as in "/v2", is a
+// directory if the repository uses the major subdirectory layout. Otherwise
+// the major version is only part of the import path and is dropped.
+func majorVersionPath(wc vendor.WorkingCopy, extra string) string {
+	major, rest := vendor.SplitMajorVersion(extra)
+	if major == "" {
+		return extra
+	}
+	fi, err := os.Stat(filepath.Join(wc.Dir(), filepath.FromSlash(major)))
+	if err == nil && fi.IsDir() {
+		return extra
 	}
-	return missing
+	return rest
 }
 
 // stripscheme removes any scheme components from url like paths.
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"go/build"
+	"os"
+	"testing"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// depset returns a Depset of packages with the imports and test imports,
+// separated by a "|", of each import path.
+func depset(imports map[string][2][]string) *vendor.Depset {
+	set := &vendor.Depset{Pkgs: make(map[string]*vendor.Pkg)}
+	for path, i := range imports {
+		set.Pkgs[path] = &vendor.Pkg{Depset: set, Package: &build.Package{
+			ImportPath:   path,
+			Imports:      i[0],
+			XTestImports: i[1],
+		}}
+	}
+	return set
+}
+
+func TestMarkTestOnly(t *testing.T) {
+	defer func(dir string) { projectDir = dir }(projectDir)
+	projectDir = t.TempDir()
+	if err := os.MkdirAll(vendorDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &vendor.Manifest{Dependencies: []vendor.Dependency{
+		{Importpath: "example.com/a", Transitive: true},
+		{Importpath: "example.com/check", Transitive: true},
+	}}
+	isTest := func(path string) bool {
+		d, err := m.GetDependencyForImportpath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.Test
+	}
+
+	// example.com/check is first only imported by the tests of example.com/a
+	a := depset(map[string][2][]string{
+		"example.com/a": {nil, {"example.com/check"}},
+	})
+	check := depset(map[string][2][]string{
+		"example.com/check": {nil, nil},
+	})
+	dsm := map[string]*vendor.Depset{"a": a, "check": check}
+	if err := markTestOnly(m, pkgs(a.Pkgs), dsm, map[string]bool{"example.com/check": true}); err != nil {
+		t.Fatal(err)
+	}
+	if !isTest("example.com/check") || isTest("example.com/a") {
+		t.Fatalf("want only example.com/check needed by tests, got %+v", m.Dependencies)
+	}
+
+	// then the package example.com/b imports it
+	b := depset(map[string][2][]string{
+		"example.com/b": {[]string{"example.com/check"}, nil},
+	})
+	dsm["b"] = b
+	m.Dependencies = append(m.Dependencies, vendor.Dependency{Importpath: "example.com/b", Transitive: true})
+	if err := markTestOnly(m, pkgs(b.Pkgs), dsm, map[string]bool{"example.com/b": true}); err != nil {
+		t.Fatal(err)
+	}
+	if isTest("example.com/check") {
+		t.Fatal("want example.com/check not only needed by tests once example.com/b imports it")
+	}
+
+	got, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range got.Dependencies {
+		if d.Test {
+			t.Errorf("want the manifest written without test only dependencies, got %+v", d)
+		}
+	}
+}
+
+func TestFetchImportListRevision(t *testing.T) {
+	defer func(l, b, tg, r string) { importList, branch, tag, revision = l, b, tg, r }(importList, branch, tag, revision)
+	importList = "imports.txt"
+	for _, flags := range [][3]string{{"master", "", ""}, {"", "v1.0.0", ""}, {"", "", "0123abc"}} {
+		branch, tag, revision = flags[0], flags[1], flags[2]
+		if err := cmdFetch.Run(context.Background(), nil); exitCode(err) != exitUsage {
+			t.Errorf("-import-list with -branch %q -tag %q -revision %q: want a usage error, got %v", branch, tag, revision, err)
+		}
+	}
+}
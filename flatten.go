@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var flatten bool // move nested vendor directories to the top-level one
+
+// flattenVendor moves the packages in the vendor directory of dep, already
+// copied in place, to the top-level vendor directory, and adds them to m as
+// hoisted from dep. A package which is already vendored, at a different
+// version, or which would conflict with a dependency of m is reported and
+// left nested. Go looks up imports in every enclosing vendor directory, so
+// the hoisted packages don't need their imports rewritten.
+func flattenVendor(m *vendor.Manifest, dep vendor.Dependency) error {
+	nested := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath), "vendor")
+	paths, err := nestedPackages(nested)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		src := filepath.Join(nested, filepath.FromSlash(path))
+		dst := filepath.Join(vendorDir(), filepath.FromSlash(path))
+		conflict, err := hoistConflict(m, dep, path, src, dst)
+		if err != nil {
+			return err
+		}
+		switch conflict {
+		case "":
+		case "same":
+			debugf("%s vendored by %s is already vendored", path, dep.Importpath)
+			if err := vendor.RemoveAll(src); err != nil {
+				return err
+			}
+			continue
+		default:
+			log.Printf("not flattening %s vendored by %s, %s", path, dep.Importpath, conflict)
+			continue
+		}
+
+		debugf("moving %s out of %s", path, dep.Importpath)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+		h := vendor.Dependency{
+			Importpath: path,
+			Transitive: true,
+			Flattened:  true,
+			Hoisted:    dep.Importpath,
+			FetchedAt:  now(),
+		}
+		if err := flattenVendor(m, h); err != nil {
+			return err
+		}
+		if h.Checksum, err = checksum(m, path); err != nil {
+			return err
+		}
+		if err := m.AddDependency(h); err != nil {
+			return err
+		}
+	}
+	return removeVendorDir(nested)
+}
+
+// hoistConflict returns why the package path vendored by dep, in src,
+// can't be moved to dst, or "same" if dst already holds the same files.
+func hoistConflict(m *vendor.Manifest, dep vendor.Dependency, path, src, dst string) (string, error) {
+	if path == dep.Importpath || strings.HasPrefix(path, dep.Importpath+"/") {
+		return "it is part of " + dep.Importpath, nil
+	}
+	if isSelf(path) {
+		return "it is part of the project", nil
+	}
+	for _, d := range m.Dependencies {
+		if strings.HasPrefix(d.Importpath, path+"/") {
+			return "it would contain " + d.Importpath, nil
+		}
+	}
+	if c, ok := caseCollision(m, path); ok && caseInsensitive {
+		return "it differs from " + c + " only by case", nil
+	}
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		return "", nil
+	}
+	if !m.HasImportpath(path) {
+		return dst + " already exists", nil
+	}
+	a, err := vendor.TreeChecksum(src)
+	if err != nil {
+		return "", err
+	}
+	b, err := vendor.TreeChecksum(dst)
+	if err != nil {
+		return "", err
+	}
+	if a != b {
+		return "a different version is already vendored", nil
+	}
+	return "same", nil
+}
+
+// nestedPackages returns the import paths of the outermost packages in the
+// vendor directory dir, if any.
+func nestedPackages(dir string) ([]string, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path == dir {
+			return nil
+		}
+		name := info.Name()
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" {
+			return filepath.SkipDir
+		}
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, fi := range files {
+			if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".go") {
+				rel, err := filepath.Rel(dir, path)
+				if err != nil {
+					return err
+				}
+				paths = append(paths, filepath.ToSlash(rel))
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// removeVendorDir removes the empty directories left in the nested vendor
+// directory dir, and dir itself with any metadata file of the vendoring tool
+// once no package is left in it.
+func removeVendorDir(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	empty, err := removeEmptyDirs(dir)
+	if err != nil || empty {
+		return err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if fi.IsDir() {
+			return nil
+		}
+	}
+	return vendor.RemoveAll(dir)
+}
+
+// removeEmptyDirs removes the directories in dir with no files, and dir
+// itself if it ends up empty, which it reports.
+func removeEmptyDirs(dir string) (bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	left := len(files)
+	for _, fi := range files {
+		if !fi.IsDir() {
+			continue
+		}
+		empty, err := removeEmptyDirs(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return false, err
+		}
+		if empty {
+			left--
+		}
+	}
+	if left > 0 {
+		return false, nil
+	}
+	return true, os.Remove(dir)
+}
+
+// placeHoisted moves again the packages hoisted from dep, as recorded in m,
+// out of its vendor directory after it was copied in place.
+func placeHoisted(m *vendor.Manifest, dep vendor.Dependency) error {
+	nested := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath), "vendor")
+	for _, h := range m.Dependencies {
+		if h.Hoisted != dep.Importpath {
+			continue
+		}
+		src := filepath.Join(nested, filepath.FromSlash(h.Importpath))
+		dst := filepath.Join(vendorDir(), filepath.FromSlash(h.Importpath))
+		if _, err := os.Stat(src); err != nil {
+			return fmt.Errorf("%s is no longer vendored by %s: %v", h.Importpath, dep.Importpath, err)
+		}
+		if _, err := os.Stat(dst); err == nil {
+			if err := vendor.RemoveAll(dst); err != nil {
+				return fmt.Errorf("dependency could not be deleted: %v", err)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+		if err := placeHoisted(m, h); err != nil {
+			return err
+		}
+	}
+	return removeVendorDir(nested)
+}
+
+// hoistedFrom returns the import paths of the dependencies of m which dep
+// was hoisted from, directly or through other hoisted ones, innermost first.
+func hoistedFrom(m *vendor.Manifest, dep vendor.Dependency) []string {
+	var paths []string
+	for dep.Hoisted != "" {
+		paths = append(paths, dep.Hoisted)
+		d, err := m.GetDependencyForImportpath(dep.Hoisted)
+		if err != nil {
+			break
+		}
+		dep = d
+	}
+	return paths
+}
+
+// removeHoisted removes from m and from the vendor directory the packages
+// hoisted from the dependency importpath.
+func removeHoisted(m *vendor.Manifest, importpath string) error {
+	for _, h := range append([]vendor.Dependency(nil), m.Dependencies...) {
+		if h.Hoisted != importpath {
+			continue
+		}
+		if err := removeHoisted(m, h.Importpath); err != nil {
+			return err
+		}
+		if err := m.RemoveDependency(h); err != nil {
+			return err
+		}
+		if err := vendor.RemoveAll(filepath.Join(vendorDir(), filepath.FromSlash(h.Importpath))); err != nil {
+			return fmt.Errorf("dependency could not be deleted: %v", err)
+		}
+	}
+	return nil
+}
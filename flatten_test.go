@@ -0,0 +1,179 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+func TestFlattenVendor(t *testing.T) {
+	defer func(dir, s string) { projectDir, self = dir, s }(projectDir, self)
+	self = "example.com/project"
+
+	const nested = "example.com/outer/vendor/"
+	tests := []struct {
+		name    string
+		deps    []vendor.Dependency // already in the manifest
+		files   map[string]string   // in the vendor directory
+		hoisted map[string]string   // import path to the one it was hoisted from
+		present []string            // files left in the vendor directory
+		missing []string            // files not in it anymore
+	}{{
+		name: "hoisted",
+		files: map[string]string{
+			"example.com/outer/outer.go":  "package outer\n",
+			nested + "example.com/a/a.go": "package a\n",
+		},
+		hoisted: map[string]string{"example.com/a": "example.com/outer"},
+		present: []string{"example.com/a/a.go"},
+		missing: []string{"example.com/outer/vendor"},
+	}, {
+		name: "nested hoisted",
+		files: map[string]string{
+			nested + "example.com/a/a.go":                      "package a\n",
+			nested + "example.com/a/vendor/example.com/b/b.go": "package b\n",
+		},
+		hoisted: map[string]string{"example.com/a": "example.com/outer", "example.com/b": "example.com/a"},
+		present: []string{"example.com/a/a.go", "example.com/b/b.go"},
+		missing: []string{"example.com/outer/vendor", "example.com/a/vendor"},
+	}, {
+		name: "same version",
+		deps: []vendor.Dependency{{Importpath: "example.com/a"}},
+		files: map[string]string{
+			"example.com/a/a.go":          "package a\n",
+			nested + "example.com/a/a.go": "package a\n",
+		},
+		present: []string{"example.com/a/a.go"},
+		missing: []string{"example.com/outer/vendor"},
+	}, {
+		name: "different version",
+		deps: []vendor.Dependency{{Importpath: "example.com/a"}},
+		files: map[string]string{
+			"example.com/a/a.go":          "package a\n",
+			nested + "example.com/a/a.go": "package a // v2\n",
+		},
+		present: []string{"example.com/a/a.go", nested + "example.com/a/a.go"},
+	}, {
+		name: "would contain",
+		deps: []vendor.Dependency{{Importpath: "example.com/a/sub"}},
+		files: map[string]string{
+			"example.com/a/sub/sub.go":    "package sub\n",
+			nested + "example.com/a/a.go": "package a\n",
+		},
+		present: []string{nested + "example.com/a/a.go"},
+		missing: []string{"example.com/a/a.go"},
+	}, {
+		name: "part of the dependency",
+		files: map[string]string{
+			nested + "example.com/outer/x/x.go": "package x\n",
+		},
+		present: []string{nested + "example.com/outer/x/x.go"},
+	}, {
+		name: "part of the project",
+		files: map[string]string{
+			nested + "example.com/project/p.go": "package project\n",
+		},
+		present: []string{nested + "example.com/project/p.go"},
+		missing: []string{"example.com/project"},
+	}, {
+		name: "not in the manifest",
+		files: map[string]string{
+			"example.com/a/a.go":          "package a\n",
+			nested + "example.com/a/a.go": "package a // v2\n",
+		},
+		present: []string{nested + "example.com/a/a.go"},
+	}, {
+		name: "metadata file",
+		files: map[string]string{
+			nested + "vendor.json":        "{}\n",
+			nested + "example.com/a/a.go": "package a\n",
+		},
+		hoisted: map[string]string{"example.com/a": "example.com/outer"},
+		present: []string{"example.com/a/a.go"},
+		missing: []string{"example.com/outer/vendor"},
+	}}
+	for _, tt := range tests {
+		projectDir = t.TempDir()
+		writeFiles(t, vendorDir(), tt.files)
+		m := &vendor.Manifest{Dependencies: append([]vendor.Dependency(nil), tt.deps...)}
+		if err := flattenVendor(m, vendor.Dependency{Importpath: "example.com/outer"}); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		hoisted := make(map[string]string)
+		for _, d := range m.Dependencies {
+			if d.Hoisted != "" {
+				hoisted[d.Importpath] = d.Hoisted
+			}
+		}
+		if len(tt.hoisted) == 0 {
+			tt.hoisted = map[string]string{}
+		}
+		if !reflect.DeepEqual(hoisted, tt.hoisted) {
+			t.Errorf("%s: want hoisted %v, got %v", tt.name, tt.hoisted, hoisted)
+		}
+		for _, name := range tt.present {
+			if _, err := os.Stat(filepath.Join(vendorDir(), filepath.FromSlash(name))); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+		}
+		for _, name := range tt.missing {
+			if _, err := os.Stat(filepath.Join(vendorDir(), filepath.FromSlash(name))); !os.IsNotExist(err) {
+				t.Errorf("%s: want %s removed, got %v", tt.name, name, err)
+			}
+		}
+	}
+}
+
+func TestRemoveVendorDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		dirs    []string
+		files   map[string]string
+		removed bool
+	}{{
+		name:    "empty directories",
+		dirs:    []string{"example.com/a/b", "example.com/c"},
+		removed: true,
+	}, {
+		name:    "metadata only",
+		dirs:    []string{"example.com/a"},
+		files:   map[string]string{"vendor.json": "{}\n", "manifest": "{}\n"},
+		removed: true,
+	}, {
+		name:  "package left",
+		dirs:  []string{"example.com/a"},
+		files: map[string]string{"vendor.json": "{}\n", "example.com/b/b.go": "package b\n"},
+	}}
+	for _, tt := range tests {
+		dir := filepath.Join(t.TempDir(), "vendor")
+		for _, d := range tt.dirs {
+			if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(d)), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		writeFiles(t, dir, tt.files)
+		if err := removeVendorDir(dir); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		_, err := os.Stat(dir)
+		if removed := os.IsNotExist(err); removed != tt.removed {
+			t.Errorf("%s: want removed %v, got %v", tt.name, tt.removed, removed)
+		}
+		if tt.removed {
+			continue
+		}
+		for name := range tt.files {
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "example.com", "a")); !os.IsNotExist(err) {
+			t.Errorf("%s: want the empty directories removed, got %v", tt.name, err)
+		}
+	}
+}
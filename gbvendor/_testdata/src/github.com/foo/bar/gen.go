@@ -0,0 +1,11 @@
+//go:build ignore
+// +build ignore
+
+// gen.go is a generator program, run with go run gen.go.
+package main
+
+import "github.com/generator/only"
+
+func main() {
+	only.Generate()
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/test/only"
+)
+
+func TestMain(t *testing.T) {
+	only.Check(t)
+}
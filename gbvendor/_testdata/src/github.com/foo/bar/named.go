@@ -0,0 +1,8 @@
+package main
+
+import (
+	_ "github.com/blank/import"
+	. "github.com/dot/import"
+)
+
+var _ = Dot
@@ -0,0 +1,6 @@
+//go:build gvt_never
+// +build gvt_never
+
+package main
+
+import "github.com/never/built"
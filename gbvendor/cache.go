@@ -0,0 +1,182 @@
+package vendor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheDir is the directory where the repositories deduced by
+// DeduceRemoteRepo are cached across runs. The cache is disabled if blank.
+var CacheDir string
+
+// CacheTTL is how long a cached repository is used before it is deduced
+// again.
+var CacheTTL = 24 * time.Hour
+
+// cachefile is the name of the repository cache in CacheDir.
+const cachefile = "repos.json"
+
+// cachedRepo is a repository deduced for the import paths inside Root.
+type cachedRepo struct {
+	VCS   string    `json:"vcs"`
+	URL   string    `json:"url"`
+	Major string    `json:"major,omitempty"` // of gopkg.in repositories
+	Time  time.Time `json:"time"`
+}
+
+var repoCache = struct {
+	sync.Mutex
+	repos map[string]cachedRepo // keyed by repository root import path
+	dir   string                // the CacheDir repos was loaded from
+}{}
+
+// loadRepoCache reads the cache file into repoCache, if it wasn't already.
+// It must be called with repoCache locked.
+func loadRepoCache() {
+	if repoCache.repos != nil && repoCache.dir == CacheDir {
+		return
+	}
+	repoCache.repos, repoCache.dir = make(map[string]cachedRepo), CacheDir
+	buf, err := ioutil.ReadFile(filepath.Join(CacheDir, cachefile))
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(buf, &repoCache.repos); err != nil {
+		Debugf("ignoring the corrupted repository cache: %v", err)
+		repoCache.repos = make(map[string]cachedRepo)
+	}
+}
+
+// lookupRepo returns the cached repository of path, and the path inside it.
+// Entries older than CacheTTL, using a protocol insecure isn't allowing
+// anymore, or of another version control system than the one set by VCS,
+// are ignored.
+func lookupRepo(path string, insecure bool) (RemoteRepo, string, bool) {
+	if CacheDir == "" || strings.Contains(path, "://") {
+		return nil, "", false
+	}
+	repoCache.Lock()
+	defer repoCache.Unlock()
+	loadRepoCache()
+
+	var root string
+	for p := range repoCache.repos {
+		if (path == p || strings.HasPrefix(path, p+"/")) && len(p) > len(root) {
+			root = p
+		}
+	}
+	c, ok := repoCache.repos[root]
+	if !ok || time.Since(c.Time) > CacheTTL {
+		return nil, "", false
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, "", false
+	}
+	if (u.Scheme == "http" || u.Scheme == "git" || u.Scheme == "svn") && !isInsecureHost(u.Host, insecure) {
+		return nil, "", false
+	}
+	if vcs := vcsFor(path); vcs != "" && vcs != c.VCS && !(vcs == "git" && c.VCS == "gopkgin") {
+		return nil, "", false
+	}
+
+	var repo RemoteRepo
+	switch c.VCS {
+	case "git":
+		repo = &gitrepo{url: c.URL}
+	case "gopkgin":
+		repo = &gopkginRepo{&gitrepo{url: c.URL}, c.Major}
+	case "hg":
+		repo = &hgrepo{url: c.URL}
+	case "bzr":
+		repo = &bzrrepo{url: c.URL}
+	case "svn":
+		repo = &svnrepo{url: c.URL}
+	default:
+		return nil, "", false
+	}
+	Debugf("using the cached repository %s for %s", c.URL, root)
+	return repo, path[len(root):], true
+}
+
+// storeRepo caches repo as the repository of the import paths inside root,
+// and writes the cache file. Failures only disable the cache.
+func storeRepo(root string, repo RemoteRepo) {
+	if CacheDir == "" || strings.Contains(root, "://") {
+		return
+	}
+	c := cachedRepo{URL: repo.URL(), Time: time.Now()}
+	switch r := repo.(type) {
+	case *gitrepo:
+		c.VCS = "git"
+	case *gopkginRepo:
+		c.VCS, c.Major = "gopkgin", r.major
+	case *hgrepo:
+		c.VCS = "hg"
+	case *bzrrepo:
+		c.VCS = "bzr"
+	case *svnrepo:
+		c.VCS = "svn"
+	default:
+		return
+	}
+
+	repoCache.Lock()
+	defer repoCache.Unlock()
+	loadRepoCache()
+	repoCache.repos[root] = c
+	if err := writeRepoCache(); err != nil {
+		Debugf("could not write the repository cache: %v", err)
+	}
+}
+
+// writeRepoCache writes repoCache to the cache file, through a temporary
+// file so that concurrent runs never read a partial one.
+func writeRepoCache() error {
+	buf, err := json.MarshalIndent(repoCache.repos, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(CacheDir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(CacheDir, cachefile+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(CacheDir, cachefile)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// ClearCache removes the cached repositories.
+func ClearCache() error {
+	repoCache.Lock()
+	defer repoCache.Unlock()
+	repoCache.repos = nil
+	if CacheDir == "" {
+		return nil
+	}
+	err := os.Remove(filepath.Join(CacheDir, cachefile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
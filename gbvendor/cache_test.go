@@ -0,0 +1,56 @@
+package vendor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepoCache(t *testing.T) {
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = t.TempDir()
+	defer ClearCache()
+
+	storeRepo("github.com/foo/bar", &gitrepo{url: "https://github.com/foo/bar"})
+	storeRepo("gopkg.in/yaml.v2", &gopkginRepo{&gitrepo{url: "https://github.com/go-yaml/yaml"}, "v2"})
+	storeRepo("example.com/insecure", &gitrepo{url: "http://example.com/insecure"})
+
+	// read the cache file again, as a later run would
+	repoCache.Lock()
+	repoCache.repos = nil
+	repoCache.Unlock()
+
+	repo, extra, ok := lookupRepo("github.com/foo/bar/baz/v2", false)
+	if !ok {
+		t.Fatal("lookupRepo: expected a cached repository")
+	}
+	if repo.URL() != "https://github.com/foo/bar" || extra != "/baz/v2" {
+		t.Fatalf("lookupRepo: want https://github.com/foo/bar and /baz/v2, got %s and %s", repo.URL(), extra)
+	}
+	if _, _, ok := lookupRepo("github.com/foo/barbaz", false); ok {
+		t.Fatal("lookupRepo: expected no repository for a sibling import path")
+	}
+	repo, _, ok = lookupRepo("gopkg.in/yaml.v2", false)
+	if g, isGopkgin := repo.(*gopkginRepo); !ok || !isGopkgin || g.major != "v2" {
+		t.Fatalf("lookupRepo: expected the gopkg.in v2 repository, got %#v", repo)
+	}
+	if _, _, ok := lookupRepo("example.com/insecure", false); ok {
+		t.Fatal("lookupRepo: expected an insecure repository to be ignored without insecure")
+	}
+	if _, _, ok := lookupRepo("example.com/insecure", true); !ok {
+		t.Fatal("lookupRepo: expected an insecure repository to be used with insecure")
+	}
+
+	defer func(ttl time.Duration) { CacheTTL = ttl }(CacheTTL)
+	CacheTTL = -time.Second
+	if _, _, ok := lookupRepo("github.com/foo/bar", false); ok {
+		t.Fatal("lookupRepo: expected an expired repository to be ignored")
+	}
+	CacheTTL = time.Hour
+
+	if err := ClearCache(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := lookupRepo("github.com/foo/bar", false); ok {
+		t.Fatal("lookupRepo: expected no repository after ClearCache")
+	}
+}
@@ -0,0 +1,62 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, contents string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTreeChecksum(t *testing.T) {
+	root := mktemp(t)
+	defer RemoveAll(root)
+
+	writeFile(t, filepath.Join(root, "a.go"), "package a\n")
+	writeFile(t, filepath.Join(root, "b", "b.go"), "package b\n")
+
+	sum := func(skip ...string) string {
+		s, err := TreeChecksum(root, skip...)
+		if err != nil {
+			t.Fatalf("TreeChecksum(%q): %v", root, err)
+		}
+		return s
+	}
+
+	orig := sum()
+	if again := sum(); again != orig {
+		t.Fatalf("TreeChecksum is not stable: %s, then %s", orig, again)
+	}
+
+	// a nested dependency which is skipped does not change the checksum
+	nested := filepath.Join(root, "nested")
+	writeFile(t, filepath.Join(nested, "n.go"), "package nested\n")
+	if got := sum(nested); got != orig {
+		t.Fatalf("skipped directory changed checksum: want %s, got %s", orig, got)
+	}
+	if got := sum(); got == orig {
+		t.Fatalf("added file did not change checksum")
+	}
+	RemoveAll(nested)
+
+	writeFile(t, filepath.Join(root, "b", "b.go"), "package b // modified\n")
+	if got := sum(); got == orig {
+		t.Fatalf("modified file did not change checksum")
+	}
+	writeFile(t, filepath.Join(root, "b", "b.go"), "package b\n")
+
+	if err := os.Rename(filepath.Join(root, "b", "b.go"), filepath.Join(root, "b", "c.go")); err != nil {
+		t.Fatal(err)
+	}
+	if got := sum(); got == orig {
+		t.Fatalf("renamed file did not change checksum")
+	}
+}
@@ -3,6 +3,7 @@ package vendor
 import (
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -32,7 +33,7 @@ func Copypath(dst string, src string) error {
 
 		if info.Mode()&os.ModeSymlink != 0 {
 			if debugCopypath {
-				fmt.Printf("skipping symlink: %v\n", path)
+				log.Printf("skipping symlink: %v", path)
 			}
 			return nil
 		}
@@ -0,0 +1,154 @@
+package vendor
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Credential is a login and password for a host.
+type Credential struct {
+	Login, Password string
+}
+
+// Credentials holds the credentials to use for each host, see credential.
+// They are passed to git and used for go-import metadata requests, and only
+// ever sent over HTTPS.
+var Credentials = make(map[string]Credential)
+
+// basicAuth returns the value of an HTTP Authorization header for c.
+func (c Credential) basicAuth() string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Login+":"+c.Password))
+}
+
+// ParseNetrc parses the machine entries of a netrc file, keyed by host.
+// The default entry and macro definitions are ignored.
+func ParseNetrc(r io.Reader) (map[string]Credential, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	creds := make(map[string]Credential)
+	var (
+		host string
+		c    Credential
+	)
+	flush := func() {
+		if host != "" {
+			creds[host] = c
+		}
+		host, c = "", Credential{}
+	}
+	for _, line := range skipMacros(string(buf)) {
+		f := strings.Fields(line)
+		for i := 0; i < len(f); i++ {
+			var next string
+			if i+1 < len(f) {
+				next = f[i+1]
+			}
+			switch f[i] {
+			case "machine":
+				flush()
+				host = next
+				i++
+			case "default":
+				flush()
+			case "login":
+				c.Login = next
+				i++
+			case "password":
+				c.Password = next
+				i++
+			case "account":
+				i++
+			}
+		}
+	}
+	flush()
+	return creds, nil
+}
+
+// skipMacros returns the lines of a netrc file, leaving out the bodies of
+// macdef entries, which run until the next blank line.
+func skipMacros(s string) []string {
+	var lines []string
+	inMacro := false
+	for _, line := range strings.Split(s, "\n") {
+		switch {
+		case inMacro:
+			inMacro = strings.TrimSpace(line) != ""
+		case strings.HasPrefix(strings.TrimSpace(line), "macdef"):
+			inMacro = true
+		default:
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// tokenEnv returns the name of the environment variable holding the token
+// for host, as in GVT_TOKEN_GITHUB_COM for github.com.
+func tokenEnv(host string) string {
+	return "GVT_TOKEN_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, host)
+}
+
+// credential returns the credential for host. A token set in the
+// environment variable named by tokenEnv takes precedence over Credentials.
+// The token may be prefixed by a login and a colon, the login defaults to
+// oauth2, which GitHub and GitLab both accept.
+func credential(host string) (Credential, bool) {
+	if token := os.Getenv(tokenEnv(host)); token != "" {
+		if i := strings.Index(token, ":"); i >= 0 {
+			return Credential{Login: token[:i], Password: token[i+1:]}, true
+		}
+		return Credential{Login: "oauth2", Password: token}, true
+	}
+	c, ok := Credentials[host]
+	return c, ok
+}
+
+// vcsEnv returns the environment to run a vcs command with args in.
+// Prompts for credentials are disabled, so that a missing one fails instead
+// of hanging, and git is configured to send the credentials of the hosts of
+// the HTTPS urls in args.
+func vcsEnv(args []string) []string {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var hosts []string
+	for _, arg := range args {
+		u, err := url.Parse(arg)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			continue
+		}
+		if _, ok := credential(u.Host); ok {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	if len(hosts) == 0 {
+		return env
+	}
+
+	// add to the configuration already passed through the environment
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	for i, host := range hosts {
+		c, _ := credential(host)
+		env = append(env,
+			"GIT_CONFIG_KEY_"+strconv.Itoa(n+i)+"=http.https://"+host+"/.extraHeader",
+			"GIT_CONFIG_VALUE_"+strconv.Itoa(n+i)+"=Authorization: "+c.basicAuth(),
+		)
+	}
+	return append(env, "GIT_CONFIG_COUNT="+strconv.Itoa(n+len(hosts)))
+}
@@ -0,0 +1,75 @@
+package vendor
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	const netrc = `machine github.com login alice password s3cret
+machine git.example.com
+	login bob
+	password hunter2
+	account ignored
+
+macdef init
+machine evil.example.com login mallory password nope
+
+default login anonymous password guest
+`
+	got, err := ParseNetrc(strings.NewReader(netrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Credential{
+		"github.com":      {"alice", "s3cret"},
+		"git.example.com": {"bob", "hunter2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseNetrc: want %v, got %v", want, got)
+	}
+}
+
+func TestVcsEnv(t *testing.T) {
+	defer func(c map[string]Credential) { Credentials = c }(Credentials)
+	Credentials = map[string]Credential{"git.example.com": {"bob", "hunter2"}}
+	defer os.Unsetenv("GVT_TOKEN_TOKEN_EXAMPLE_COM_8443")
+	os.Setenv("GVT_TOKEN_TOKEN_EXAMPLE_COM_8443", "t0ken")
+
+	got := strings.Join(vcsEnv([]string{"git", "clone", "https://git.example.com/foo", "/tmp/foo"}), "\n")
+	for _, want := range []string{
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_KEY_0=http.https://git.example.com/.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic Ym9iOmh1bnRlcjI=",
+		"GIT_CONFIG_COUNT=1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("vcsEnv: %q missing", want)
+		}
+	}
+
+	got = strings.Join(vcsEnv([]string{"git", "ls-remote", "https://token.example.com:8443/foo", "HEAD"}), "\n")
+	if want := "GIT_CONFIG_VALUE_0=Authorization: Basic b2F1dGgyOnQwa2Vu"; !strings.Contains(got, want) {
+		t.Errorf("vcsEnv: %q missing", want)
+	}
+
+	// credentials are never sent in the clear
+	got = strings.Join(vcsEnv([]string{"git", "ls-remote", "http://git.example.com/foo", "HEAD"}), "\n")
+	if strings.Contains(got, "GIT_CONFIG_COUNT") {
+		t.Errorf("vcsEnv: unexpected credentials for http url")
+	}
+}
+
+func TestTokenEnv(t *testing.T) {
+	for host, want := range map[string]string{
+		"github.com":           "GVT_TOKEN_GITHUB_COM",
+		"git.example.com:8443": "GVT_TOKEN_GIT_EXAMPLE_COM_8443",
+		"my-gitlab.io":         "GVT_TOKEN_MY_GITLAB_IO",
+	} {
+		if got := tokenEnv(host); got != want {
+			t.Errorf("tokenEnv(%q): want %q, got %q", host, want, got)
+		}
+	}
+}
@@ -42,7 +42,7 @@ func LoadTree(root string, prefix string) (*Depset, error) {
 		Pkgs:   make(map[string]*Pkg),
 	}
 	fn := func(dir string, fi os.FileInfo) error {
-		importpath := filepath.Join(prefix, dir[len(root)+1:])
+		importpath := importPath(prefix, dir[len(root)+1:])
 
 		// if we're at the root of a tree, skip it
 		if importpath == "" {
@@ -56,7 +56,7 @@ func LoadTree(root string, prefix string) (*Depset, error) {
 			}
 			return fmt.Errorf("loadPackage(%q, %q): %v", dir, importpath, err)
 		}
-		p.ImportPath = filepath.ToSlash(importpath)
+		p.ImportPath = importpath
 		if p != nil {
 			d.Pkgs[p.ImportPath] = p
 		}
@@ -77,6 +77,30 @@ func LoadTree(root string, prefix string) (*Depset, error) {
 	return &d, err
 }
 
+// NonCanonicalImports loads the tree of packages in root, which is vendored
+// as prefix, and returns the import paths of the packages whose import
+// comment, as in package foo // import "example.com/foo", declares another
+// canonical import path, mapped to the latter. Packages in a vendor
+// directory inside root are left out.
+func NonCanonicalImports(root, prefix string) (map[string]string, error) {
+	d, err := LoadTree(root, prefix)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]string)
+	prefix = slashPath(prefix)
+	for path, p := range d.Pkgs {
+		// only the directories inside root, prefix may well contain vendor
+		if strings.Contains("/"+strings.TrimPrefix(path, prefix)+"/", "/vendor/") {
+			continue
+		}
+		if p.ImportComment != "" && p.ImportComment != path {
+			paths[path] = p.ImportComment
+		}
+	}
+	return paths, nil
+}
+
 func loadPackage(d *Depset, dir string) (*Pkg, error) {
 	p := Pkg{
 		Depset: d,
@@ -84,7 +108,7 @@ func loadPackage(d *Depset, dir string) (*Pkg, error) {
 	var err error
 
 	// expolit local import logic
-	p.Package, err = build.ImportDir(dir, build.ImportComment)
+	p.Package, err = importDir(dir, build.ImportComment)
 	return &p, err
 }
 
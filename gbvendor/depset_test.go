@@ -0,0 +1,91 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLoadTree(t *testing.T) {
+	root := filepath.Join(getwd(t), "_testdata", "vendor", "src")
+	tests := []struct {
+		root, prefix string
+		want         []string
+	}{{
+		root: root,
+		want: []string{"bitbucket.org/fwoop/ftang", "github.com/hoo/wuu", "github.com/lypo/moopo", "github.com/quux/flobble"},
+	}, {
+		// prefixes are passed with the separator of the OS, as
+		// filepath.FromSlash returns them, import paths must not.
+		root:   filepath.Join(root, "github.com", "lypo"),
+		prefix: filepath.FromSlash("github.com/lypo"),
+		want:   []string{"github.com/lypo/moopo"},
+	}, {
+		// as on Windows, whatever the OS running the test
+		root:   filepath.Join(root, "github.com", "lypo"),
+		prefix: `github.com\lypo`,
+		want:   []string{"github.com/lypo/moopo"},
+	}}
+	for _, tt := range tests {
+		d, err := LoadTree(tt.root, tt.prefix)
+		if err != nil {
+			t.Fatalf("LoadTree(%q, %q): %v", tt.root, tt.prefix, err)
+		}
+		var got []string
+		for path, p := range d.Pkgs {
+			if path != p.ImportPath {
+				t.Errorf("LoadTree(%q, %q): package %q has import path %q", tt.root, tt.prefix, path, p.ImportPath)
+			}
+			got = append(got, path)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LoadTree(%q, %q): want %v, got %v", tt.root, tt.prefix, tt.want, got)
+		}
+	}
+}
+
+func TestNonCanonicalImports(t *testing.T) {
+	root := mktemp(t)
+	defer RemoveAll(root)
+	for path, src := range map[string]string{
+		"a.go":                      "package foo // import \"example.com/foo\"\n",
+		"bar/bar.go":                "package bar // import \"github.com/Old/foo/bar\"\n",
+		"baz/baz.go":                "package baz\n",
+		"vendor/example.com/x/x.go": "package x // import \"example.com/x\"\n",
+	} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := mkdir(filepath.Dir(path)); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   map[string]string
+	}{{
+		prefix: "github.com/Old/foo",
+		want:   map[string]string{"github.com/Old/foo": "example.com/foo"},
+	}, {
+		// a vendor element in the import path of the dependency itself
+		prefix: "github.com/vendor/foo",
+		want: map[string]string{
+			"github.com/vendor/foo":     "example.com/foo",
+			"github.com/vendor/foo/bar": "github.com/Old/foo/bar",
+		},
+	}}
+	for _, tt := range tests {
+		got, err := NonCanonicalImports(root, filepath.FromSlash(tt.prefix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NonCanonicalImports(%q): want %v, got %v", tt.prefix, tt.want, got)
+		}
+	}
+}
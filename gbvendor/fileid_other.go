@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package vendor
+
+import "os"
+
+// idOf reports false, FileInfo has no inode on this platform.
+func idOf(fi os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,159 @@
+package vendor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// gopkg.in import paths carry the major version of the package, which
+// gopkg.in serves from the branch or tag of the underlying GitHub
+// repository with the highest matching version.
+//
+//	gopkg.in/pkg.v3      -> github.com/go-pkg/pkg
+//	gopkg.in/user/pkg.v3 -> github.com/user/pkg
+var gopkginregex = regexp.MustCompile(`^gopkg\.in/(?:([a-zA-Z0-9][-a-zA-Z0-9]*)/)?([a-zA-Z][-.a-zA-Z0-9]*)\.(v0|v[1-9][0-9]*)((?:/[a-zA-Z0-9][-.a-zA-Z0-9]*)*)$`)
+
+// gopkginRepo is the GitHub repository behind a gopkg.in import path.
+type gopkginRepo struct {
+	*gitrepo
+
+	// major is the version channel of the import path, as in "v3".
+	major string
+}
+
+// gopkginrepo returns the RemoteRepo behind the gopkg.in import path, and
+// the path of the package inside the repository.
+func gopkginrepo(ctx context.Context, path string, insecure bool, schemes ...string) (RemoteRepo, string, error) {
+	v := gopkginregex.FindStringSubmatch(path)
+	user, pkg, major, extra := v[1], v[2], v[3], v[4]
+	if user == "" {
+		user = "go-" + pkg
+	}
+	repo, err := newGitrepo(ctx, &url.URL{Host: "github.com", Path: user + "/" + pkg}, insecure, schemes...)
+	if err != nil {
+		return nil, "", err
+	}
+	return &gopkginRepo{repo.(*gitrepo), major}, extra, nil
+}
+
+// Checkout checks out the branch or tag with the highest version in the
+// channel of the import path if branch is blank or the channel itself, and
+// behaves like a git Checkout otherwise. The Branch of the working copy is
+// then the channel, so that updating follows it. gopkg.in serves the channel
+// as its master branch, as recorded by older versions, so master is
+// treated as the channel too.
+func (g *gopkginRepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+	return g.checkout(context.Background(), branch, tag, revision)
+}
+
+func (g *gopkginRepo) checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error) {
+	if (branch != "" && branch != g.major && branch != "master") || tag != "" || revision != "" {
+		return g.gitrepo.checkout(ctx, branch, tag, revision)
+	}
+	out, err := run(ctx, "git", "ls-remote", "--heads", "--tags", mirrorURL(g.url))
+	if err != nil {
+		return nil, err
+	}
+	ref, isTag, ok := bestVersionRef(out, g.major)
+	if !ok {
+		// gopkg.in falls back to the default branch for v0
+		if g.major != "v0" {
+			return nil, fmt.Errorf("%s has no branch or tag matching %s", g.url, g.major)
+		}
+		ref = ""
+	}
+	var wc WorkingCopy
+	if isTag {
+		wc, err = g.gitrepo.checkout(ctx, "", ref, "")
+	} else {
+		wc, err = g.gitrepo.checkout(ctx, ref, "", "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gopkginClone{wc.(*GitClone), g.major}, nil
+}
+
+// gopkginClone is a checkout of a gopkg.in version channel.
+type gopkginClone struct {
+	*GitClone
+	major string
+}
+
+func (g *gopkginClone) Branch() (string, error) { return g.major, nil }
+
+// bestVersionRef returns the name of the branch or tag listed by git
+// ls-remote in out with the highest version in the major channel, as in
+// "v3", "v3.1" or "v3.1.4" for "v3". A branch wins over a tag of the same
+// version.
+func bestVersionRef(out []byte, major string) (name string, isTag, ok bool) {
+	var best []int
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		var ref string
+		var tag bool
+		switch {
+		case strings.HasPrefix(fields[1], "refs/heads/"):
+			ref = strings.TrimPrefix(fields[1], "refs/heads/")
+		case strings.HasPrefix(fields[1], "refs/tags/") && !strings.HasSuffix(fields[1], "^{}"):
+			ref, tag = strings.TrimPrefix(fields[1], "refs/tags/"), true
+		default:
+			continue
+		}
+		v, vok := parseVersion(ref)
+		if !vok || "v"+strconv.Itoa(v[0]) != major {
+			continue
+		}
+		if c := compareVersions(v, best); ok && (c < 0 || (c == 0 && (tag || !isTag))) {
+			continue
+		}
+		name, isTag, ok, best = ref, tag, true, v
+	}
+	return name, isTag, ok
+}
+
+// parseVersion parses "vN", "vN.M" or "vN.M.P", the missing numbers being
+// zero.
+func parseVersion(s string) ([]int, bool) {
+	if !strings.HasPrefix(s, "v") {
+		return nil, false
+	}
+	parts := strings.Split(s[1:], ".")
+	if len(parts) > 3 {
+		return nil, false
+	}
+	v := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || (len(p) > 1 && p[0] == '0') {
+			return nil, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func compareVersions(a, b []int) int {
+	for i := range a {
+		if i >= len(b) {
+			return 1
+		}
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
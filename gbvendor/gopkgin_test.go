@@ -0,0 +1,65 @@
+package vendor
+
+import "testing"
+
+func TestGopkginRegex(t *testing.T) {
+	tests := []struct {
+		path                    string
+		user, pkg, major, extra string
+	}{
+		{"gopkg.in/yaml.v2", "", "yaml", "v2", ""},
+		{"gopkg.in/check.v1", "", "check", "v1", ""},
+		{"gopkg.in/mgo.v2/bson", "", "mgo", "v2", "/bson"},
+		{"gopkg.in/src-d/go-git.v4/plumbing", "src-d", "go-git", "v4", "/plumbing"},
+		{"gopkg.in/fsnotify/fsnotify.v1", "fsnotify", "fsnotify", "v1", ""},
+	}
+	for _, tt := range tests {
+		v := gopkginregex.FindStringSubmatch(tt.path)
+		if v == nil {
+			t.Errorf("%q: no match", tt.path)
+			continue
+		}
+		if v[1] != tt.user || v[2] != tt.pkg || v[3] != tt.major || v[4] != tt.extra {
+			t.Errorf("%q: want %q %q %q %q, got %q %q %q %q", tt.path, tt.user, tt.pkg, tt.major, tt.extra, v[1], v[2], v[3], v[4])
+		}
+	}
+
+	for _, path := range []string{"gopkg.in/yaml", "gopkg.in/yaml.v01", "github.com/go-yaml/yaml.v2"} {
+		if gopkginregex.MatchString(path) {
+			t.Errorf("%q: unexpected match", path)
+		}
+	}
+}
+
+func TestBestVersionRef(t *testing.T) {
+	refs := []byte(`0c1a2b	refs/heads/master
+1d2e3f	refs/heads/v2
+2e3f4a	refs/tags/v1.9.0
+3f4a5b	refs/tags/v2.1.0
+4a5b6c	refs/tags/v2.1.0^{}
+5b6c7d	refs/tags/v2.4.0
+6c7d8e	refs/tags/v2.10
+7d8e9f	refs/tags/v3.0.0
+8e9fa0	refs/tags/v3.0.0-rc1
+9fa0b1	refs/heads/v4
+a0b1c2	refs/tags/v4
+`)
+	tests := []struct {
+		major string
+		name  string
+		isTag bool
+		ok    bool
+	}{
+		{"v1", "v1.9.0", true, true},
+		{"v2", "v2.10", true, true},
+		{"v3", "v3.0.0", true, true},
+		{"v4", "v4", false, true},
+		{"v5", "", false, false},
+	}
+	for _, tt := range tests {
+		name, isTag, ok := bestVersionRef(refs, tt.major)
+		if name != tt.name || isTag != tt.isTag || ok != tt.ok {
+			t.Errorf("bestVersionRef(%s): want %q %v %v, got %q %v %v", tt.major, tt.name, tt.isTag, tt.ok, name, isTag, ok)
+		}
+	}
+}
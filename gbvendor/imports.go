@@ -1,49 +1,284 @@
 package vendor
 
 import (
+	"errors"
 	"fmt"
+	"go/build"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// ParseImports parses Go packages from a specific root returning a set of import paths.
-func ParseImports(root string) (map[string]bool, error) {
+// ParseImports parses Go packages from a specific root returning the set of
+// import paths that have to be fetched.
+// Files excluded by build constraints for the current GOOS and GOARCH, or
+// for all of Platforms if set, are ignored, and so are test files unless
+// tests is true. Directories named
+// vendor are skipped, and so are the directories in skip.
+func ParseImports(root string, tests bool, skip ...string) (map[string]bool, error) {
+	dirs, err := ParsePackageImports(root, tests, skip...)
 	pkgs := make(map[string]bool)
+	for _, imports := range dirs {
+		for p := range imports {
+			pkgs[p] = true
+		}
+	}
+	return pkgs, err
+}
+
+// ExcludeDirs are patterns, in the syntax of path.Match, of directories
+// skipped by ParseImports and ParsePackageImports along with everything
+// inside them. They match slash separated paths relative to the root.
+var ExcludeDirs []string
+
+func isExcludedDir(rel string) bool {
+	for _, pattern := range ExcludeDirs {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePackageImports is like ParseImports, but returns the import paths
+// separately for each directory, keyed by its slash separated path relative
+// to root. Directories without such imports are omitted.
+func ParsePackageImports(root string, tests bool, skip ...string) (map[string]map[string]bool, error) {
+	dirs := make(map[string]map[string]bool)
+
+	stdlib, err := stdlibPackages(build.Default.GOROOT)
+	if err != nil {
+		return nil, fmt.Errorf("could not list standard library packages: %v", err)
+	}
 
 	var walkFn = func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if info.IsDir() {
+			if path == root {
+				return nil
+			}
 			name := info.Name()
 			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" {
 				return filepath.SkipDir
 			}
+			// vendored packages are dependencies, not part of the tree
+			if name == "vendor" {
+				return filepath.SkipDir
+			}
+			for _, s := range skip {
+				if path == s {
+					return filepath.SkipDir
+				}
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if isExcludedDir(filepath.ToSlash(rel)) {
+				Debugf("skipping excluded directory %s", path)
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if filepath.Ext(path) != ".go" { // Parse only go source files
 			return nil
 		}
+		if !tests && strings.HasSuffix(info.Name(), "_test.go") {
+			return nil
+		}
+
+		ok, err := matchFile(filepath.Dir(path), info.Name())
+		if err != nil {
+			return parseError(path, err)
+		}
+		if !ok {
+			return nil
+		}
 
 		fs := token.NewFileSet()
 		f, err := parser.ParseFile(fs, path, nil, parser.ImportsOnly)
 		if err != nil {
-			return err
+			return parseError(path, err)
 		}
 
 		for _, s := range f.Imports {
+			// blank and dot imports only set s.Name, the path is
+			// the same as for a plain import.
 			p := strings.Replace(s.Path.Value, "\"", "", -1)
-			if !contains(stdlib, p) {
-				pkgs[p] = true
+			if p == "C" {
+				// the cgo pseudo-package
+				continue
+			}
+			if !isRemoteImport(stdlib, p) {
+				continue
+			}
+			rel, err := filepath.Rel(root, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if dirs[rel] == nil {
+				dirs[rel] = make(map[string]bool)
 			}
+			dirs[rel][p] = true
 		}
 		return nil
 	}
 
-	err := filepath.Walk(root, walkFn)
-	return pkgs, err
+	err = walkSymlinks(root, FollowSymlinks, walkFn)
+	return dirs, err
+}
+
+// SkipParseError, if not nil, is called with each file ParseImports and
+// ParsePackageImports fail to parse, which is then skipped instead of
+// failing the whole parse.
+var SkipParseError func(path string, err error)
+
+// ParseError is an error parsing the Go file at Path.
+type ParseError struct {
+	Path string
+
+	// Line and Column locate the first error, if known.
+	Line, Column int
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// parseError returns the error parsing path as a *ParseError, unless
+// SkipParseError is set.
+func parseError(path string, err error) error {
+	perr := &ParseError{Path: path, Err: err}
+	if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+		perr.Line, perr.Column = list[0].Pos.Line, list[0].Pos.Column
+		perr.Err = errors.New(list[0].Msg)
+		if len(list) > 1 {
+			perr.Err = fmt.Errorf("%s (and %d more errors)", list[0].Msg, len(list)-1)
+		}
+	}
+	err = perr
+	if SkipParseError != nil {
+		SkipParseError(path, err)
+		return nil
+	}
+	return err
+}
+
+// FollowSymlinks makes ParseImports and ParsePackageImports walk the
+// directories symlinks point to, as if they were inside the tree.
+var FollowSymlinks bool
+
+// Debugf prints debug messages. It does nothing unless replaced.
+var Debugf = func(format string, args ...interface{}) {}
+
+// Warnf prints warnings. It logs them unless replaced.
+var Warnf = func(format string, args ...interface{}) {
+	log.Printf("warning: "+format, args...)
+}
+
+type namedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi namedFileInfo) Name() string { return fi.name }
+
+// fileID identifies a file by device and inode.
+type fileID struct {
+	dev, ino uint64
+}
+
+// visitedDirs records the directories walked by walkSymlinks.
+type visitedDirs struct {
+	ids   map[fileID]bool
+	infos []os.FileInfo // on the platforms without inodes
+}
+
+// visit records fi, reporting whether it was already visited.
+func (v *visitedDirs) visit(fi os.FileInfo) bool {
+	if id, ok := idOf(fi); ok {
+		if v.ids[id] {
+			return true
+		}
+		if v.ids == nil {
+			v.ids = make(map[fileID]bool)
+		}
+		v.ids[id] = true
+		return false
+	}
+	for _, i := range v.infos {
+		if os.SameFile(i, fi) {
+			return true
+		}
+	}
+	v.infos = append(v.infos, fi)
+	return false
+}
+
+// walkSymlinks is like filepath.Walk, but if follow is set symlinks to
+// directories are walked as well, with paths under the symlink. Each
+// directory is only walked once, so that symlinks pointing back into the
+// tree don't loop. Symlinks to directories are otherwise skipped.
+func walkSymlinks(root string, follow bool, fn filepath.WalkFunc) error {
+	var visited visitedDirs
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return fn(dir, nil, err)
+		}
+		return filepath.Walk(real, func(path string, info os.FileInfo, err error) error {
+			rel, rerr := filepath.Rel(real, path)
+			if rerr != nil {
+				return rerr
+			}
+			path = filepath.Join(dir, rel)
+			if err != nil {
+				return fn(path, info, err)
+			}
+			// without following symlinks no directory is walked twice
+			if follow && info.IsDir() && visited.visit(info) {
+				Debugf("skipping %s, it was already walked through a symlink", path)
+				return filepath.SkipDir
+			}
+			if rel == "." && dir != root {
+				// the directory is named after the symlink
+				info = namedFileInfo{info, filepath.Base(dir)}
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				if target, err := os.Stat(path); err == nil && target.IsDir() {
+					if !follow {
+						Debugf("skipping symlinked directory %s", path)
+						return nil
+					}
+					return walk(path)
+				}
+			}
+			return fn(path, info, nil)
+		})
+	}
+	return walk(root)
 }
 
 // FetchMetadata fetchs the remote metadata for path.
@@ -59,28 +294,127 @@ func FetchMetadata(path string, insecure bool) (rc io.ReadCloser, err error) {
 		return
 	}
 	// try http if supported
-	if insecure {
+	if isInsecureHost(strings.SplitN(path, "/", 2)[0], insecure) {
 		rc, err = fetchMetadata("http", path)
 	}
 	return
 }
 
+// MetadataTimeout bounds each request for remote metadata, zero means no
+// timeout.
+var MetadataTimeout = 30 * time.Second
+
+// proxy selects the proxy of each metadata request. It honors HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY, and is replaced in tests.
+var proxy = http.ProxyFromEnvironment
+
+// metadataTransport is http.DefaultTransport, with its timeouts and HTTP/2
+// support, going through proxy.
+var metadataTransport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = func(r *http.Request) (*url.URL, error) { return proxy(r) }
+	return t
+}()
+
 func fetchMetadata(scheme, path string) (io.ReadCloser, error) {
 	url := fmt.Sprintf("%s://%s?go-get=1", scheme, path)
 	switch scheme {
 	case "https", "http":
-		resp, err := http.Get(url)
+		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to access url %q", url)
+			return nil, err
+		}
+		host := strings.SplitN(path, "/", 2)[0]
+		if c, ok := credential(host); ok && scheme == "https" {
+			req.Header.Set("Authorization", c.basicAuth())
+		}
+		client := &http.Client{Transport: metadataTransport, Timeout: MetadataTimeout}
+		for attempt := 0; ; attempt++ {
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, fmt.Errorf("failed to access url %q", url)
+			}
+			wait, limited := retryAfter(resp)
+			if !limited {
+				return resp.Body, nil
+			}
+			resp.Body.Close()
+			switch {
+			case wait < 0:
+				return nil, fmt.Errorf("%s rate limited the request to %q", host, url)
+			case attempt >= MetadataRetries:
+				return nil, fmt.Errorf("%s still rate limited the request to %q after %d retries", host, url, attempt)
+			case wait > MaxRetryAfter:
+				return nil, fmt.Errorf("%s rate limited the request to %q for %v, longer than %v", host, url, wait, MaxRetryAfter)
+			}
+			log.Printf("%s rate limited the request for %s, retrying in %v", host, path, wait)
+			sleep(wait)
 		}
-		return resp.Body, nil
 	default:
 		return nil, fmt.Errorf("unknown remote protocol scheme: %q", scheme)
 	}
 }
 
+// MetadataRetries is how many times a metadata request rate limited by the
+// host is retried, after waiting as long as its Retry-After header asks.
+var MetadataRetries = 3
+
+// MaxRetryAfter is the longest Retry-After a metadata request waits for,
+// it fails instead if the host asks for more.
+var MaxRetryAfter = time.Minute
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// retryAfter reports whether resp is a rate limiting response, a 429, or a
+// 403 with a Retry-After header as GitHub sends, and how long it asks to
+// wait for. The wait is negative if a 429 doesn't say.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		return 0, false
+	}
+	h := resp.Header.Get("Retry-After")
+	if s, err := strconv.Atoi(h); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return -1, true
+	}
+	return 0, false
+}
+
+// metadataCache holds the go-import metadata already resolved, so that the
+// packages of a vanity import path are only looked up once.
+var metadataCache struct {
+	sync.Mutex
+	imports []metaImport
+}
+
+func cachedMetadata(path string) (metaImport, bool) {
+	metadataCache.Lock()
+	defer metadataCache.Unlock()
+	for _, im := range metadataCache.imports {
+		if path == im.Prefix || strings.HasPrefix(path, im.Prefix+"/") {
+			return im, true
+		}
+	}
+	return metaImport{}, false
+}
+
 // ParseMetadata fetchs and decodes remote metadata for path.
+// Results are cached, a path below an import prefix already resolved
+// doesn't cause another request.
 func ParseMetadata(path string, insecure bool) (string, string, string, error) {
+	if im, ok := cachedMetadata(path); ok {
+		return im.Prefix, im.VCS, im.RepoRoot, nil
+	}
+
 	rc, err := FetchMetadata(path, insecure)
 	if err != nil {
 		return "", "", "", err
@@ -93,7 +427,9 @@ func ParseMetadata(path string, insecure bool) (string, string, string, error) {
 	}
 	match := -1
 	for i, im := range imports {
-		if !strings.HasPrefix(path, im.Prefix) {
+		// the prefix must be whole path elements, golang.org/x/tools
+		// is not the repository root of golang.org/x/toolsmith
+		if path != im.Prefix && !strings.HasPrefix(path, im.Prefix+"/") {
 			continue
 		}
 		if match != -1 {
@@ -104,5 +440,8 @@ func ParseMetadata(path string, insecure bool) (string, string, string, error) {
 	if match == -1 {
 		return "", "", "", fmt.Errorf("go-import metadata not found")
 	}
+	metadataCache.Lock()
+	metadataCache.imports = append(metadataCache.imports, imports[match])
+	metadataCache.Unlock()
 	return imports[match].Prefix, imports[match].VCS, imports[match].RepoRoot, nil
 }
@@ -2,25 +2,91 @@ package vendor
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseImports(t *testing.T) {
 	root := filepath.Join(getwd(t), "_testdata", "src")
 
-	got, err := ParseImports(root)
+	got, err := ParseImports(root, false)
 	if err != nil {
 		t.Fatalf("ParseImports(%q): %v", root, err)
 	}
 
-	want := set("github.com/quux/flobble", "github.com/lypo/moopo", "github.com/hoo/wuu")
+	want := set("github.com/quux/flobble", "github.com/lypo/moopo", "github.com/hoo/wuu", "github.com/blank/import", "github.com/dot/import")
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("ParseImports(%q): want: %v, got %v", root, want, got)
 	}
+
+	got, err = ParseImports(root, true)
+	if err != nil {
+		t.Fatalf("ParseImports(%q, true): %v", root, err)
+	}
+
+	want["github.com/test/only"] = true
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseImports(%q, true): want: %v, got %v", root, want, got)
+	}
+}
+
+func TestParsePackageImports(t *testing.T) {
+	root := filepath.Join(getwd(t), "_testdata", "src")
+
+	got, err := ParsePackageImports(root, false)
+	if err != nil {
+		t.Fatalf("ParsePackageImports(%q): %v", root, err)
+	}
+
+	want := map[string]map[string]bool{
+		"github.com/foo/bar": set("github.com/quux/flobble", "github.com/lypo/moopo", "github.com/hoo/wuu", "github.com/blank/import", "github.com/dot/import"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParsePackageImports(%q): want: %v, got %v", root, want, got)
+	}
+
+	skip := filepath.Join(root, "github.com", "foo", "bar")
+	got, err = ParsePackageImports(root, false, skip)
+	if err != nil {
+		t.Fatalf("ParsePackageImports(%q, %q): %v", root, skip, err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("ParsePackageImports(%q, %q): want no packages, got %v", root, skip, got)
+	}
+}
+
+func TestParsePackageImportsExcludeDirs(t *testing.T) {
+	root := filepath.Join(getwd(t), "_testdata", "src")
+	defer func(e []string) { ExcludeDirs = e }(ExcludeDirs)
+
+	for _, tt := range []struct {
+		pattern string
+		want    int
+	}{
+		{"github.com/*", 0},
+		{"github.com", 0},
+		{"github.com/foo/ba", 1},
+		{"*/foo/bar/*", 1},
+	} {
+		ExcludeDirs = []string{tt.pattern}
+		got, err := ParsePackageImports(root, false)
+		if err != nil {
+			t.Fatalf("ParsePackageImports(%q) excluding %q: %v", root, tt.pattern, err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("ParsePackageImports(%q) excluding %q: want %d packages, got %v", root, tt.pattern, tt.want, got)
+		}
+	}
 }
 
 func TestFetchMetadata(t *testing.T) {
@@ -152,6 +218,180 @@ func TestParseMetadata(t *testing.T) {
 	}
 }
 
+func TestParseMetadataCached(t *testing.T) {
+	metadataCache.Lock()
+	saved := metadataCache.imports
+	metadataCache.imports = []metaImport{{
+		Prefix:   "example.invalid/foo",
+		VCS:      "git",
+		RepoRoot: "https://git.example.invalid/foo",
+	}}
+	metadataCache.Unlock()
+	defer func() {
+		metadataCache.Lock()
+		metadataCache.imports = saved
+		metadataCache.Unlock()
+	}()
+
+	// example.invalid can't resolve, so these must be served from the cache
+	for _, path := range []string{"example.invalid/foo", "example.invalid/foo/bar/baz"} {
+		importpath, vcs, reporoot, err := ParseMetadata(path, false)
+		if err != nil {
+			t.Fatalf("ParseMetadata(%q): %v", path, err)
+		}
+		if importpath != "example.invalid/foo" || vcs != "git" || reporoot != "https://git.example.invalid/foo" {
+			t.Errorf("ParseMetadata(%q): got %s %s %s", path, importpath, vcs, reporoot)
+		}
+	}
+
+	if _, _, _, err := ParseMetadata("example.invalid/foobar", false); err == nil {
+		t.Errorf("ParseMetadata(%q): expected error", "example.invalid/foobar")
+	}
+}
+
+func TestParseMetadataSubpackages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// answer for any package with the metadata of every repository,
+		// some of them prefixes of the others but not whole path elements
+		for _, repo := range []string{"tools", "toolsmith", "net"} {
+			fmt.Fprintf(w, `<meta name="go-import" content="golang.org/x/%s git https://go.googlesource.com/%s"/>`, repo, repo)
+		}
+	}))
+	defer srv.Close()
+
+	defer func(p func(*http.Request) (*url.URL, error)) { proxy = p }(proxy)
+	proxy = func(*http.Request) (*url.URL, error) { return url.Parse(srv.URL) }
+	metadataCache.Lock()
+	saved := metadataCache.imports
+	metadataCache.imports = nil
+	metadataCache.Unlock()
+	defer func() {
+		metadataCache.Lock()
+		metadataCache.imports = saved
+		metadataCache.Unlock()
+	}()
+	defer func(h map[string]bool) { InsecureHosts = h }(InsecureHosts)
+	InsecureHosts = map[string]bool{"golang.org": true}
+
+	tests := []struct {
+		path, importpath, reporoot string
+	}{
+		{"golang.org/x/tools/go/packages", "golang.org/x/tools", "https://go.googlesource.com/tools"},
+		{"golang.org/x/tools/internal/lsp/protocol", "golang.org/x/tools", "https://go.googlesource.com/tools"},
+		{"golang.org/x/net/http2/hpack", "golang.org/x/net", "https://go.googlesource.com/net"},
+		{"golang.org/x/net/context", "golang.org/x/net", "https://go.googlesource.com/net"},
+		{"golang.org/x/tools", "golang.org/x/tools", "https://go.googlesource.com/tools"},
+		{"golang.org/x/toolsmith/cmd/toolsmith", "golang.org/x/toolsmith", "https://go.googlesource.com/toolsmith"},
+	}
+	for _, tt := range tests {
+		importpath, vcs, reporoot, err := ParseMetadata(tt.path, false)
+		if err != nil {
+			t.Fatalf("ParseMetadata(%q): %v", tt.path, err)
+		}
+		if importpath != tt.importpath || vcs != "git" || reporoot != tt.reporoot {
+			t.Errorf("ParseMetadata(%q): want %s git %s, got %s %s %s", tt.path, tt.importpath, tt.reporoot, importpath, vcs, reporoot)
+		}
+	}
+}
+
+func TestFetchMetadataProxy(t *testing.T) {
+	var host string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.URL.Host
+		io.WriteString(w, `<meta name="go-import" content="example.invalid/foo git https://git.example.invalid/foo">`)
+	}))
+	defer srv.Close()
+
+	defer func(p func(*http.Request) (*url.URL, error)) { proxy = p }(proxy)
+	proxy = func(*http.Request) (*url.URL, error) { return url.Parse(srv.URL) }
+
+	r, err := fetchMetadata("http", "example.invalid/foo")
+	if err != nil {
+		t.Fatalf("fetchMetadata: %v", err)
+	}
+	r.Close()
+	if host != "example.invalid" {
+		t.Fatalf("expected the request for example.invalid to go through the proxy, got %q", host)
+	}
+}
+
+func TestFetchMetadataRetryAfter(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Host {
+		case "limited.invalid":
+			if requests < 3 {
+				w.Header().Set("Retry-After", "7")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "forbidden.invalid":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		case "nohint.invalid":
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, `<meta name="go-import" content="limited.invalid/foo git https://git.example.invalid/foo">`)
+	}))
+	defer srv.Close()
+
+	defer func(p func(*http.Request) (*url.URL, error)) { proxy = p }(proxy)
+	proxy = func(*http.Request) (*url.URL, error) { return url.Parse(srv.URL) }
+	var waited []time.Duration
+	defer func(s func(time.Duration)) { sleep = s }(sleep)
+	sleep = func(d time.Duration) { waited = append(waited, d) }
+
+	r, err := fetchMetadata("http", "limited.invalid/foo")
+	if err != nil {
+		t.Fatalf("fetchMetadata: %v", err)
+	}
+	r.Close()
+	if want := []time.Duration{7 * time.Second, 7 * time.Second}; !reflect.DeepEqual(waited, want) {
+		t.Fatalf("fetchMetadata: expected to wait %v, waited %v", want, waited)
+	}
+
+	for _, path := range []string{"forbidden.invalid/foo", "nohint.invalid/foo"} {
+		waited, requests = nil, 0
+		if _, err := fetchMetadata("http", path); err == nil {
+			t.Errorf("fetchMetadata(%q): expected an error", path)
+		}
+		if len(waited) != 0 || requests != 1 {
+			t.Errorf("fetchMetadata(%q): expected a single request without waiting, got %d requests and waited %v", path, requests, waited)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		status   int
+		header   string
+		limited  bool
+		min, max time.Duration
+	}{
+		{http.StatusOK, "", false, 0, 0},
+		{http.StatusForbidden, "", false, 0, 0},
+		{http.StatusForbidden, "30", true, 30 * time.Second, 30 * time.Second},
+		{http.StatusTooManyRequests, "", true, -1, -1},
+		{http.StatusTooManyRequests, "0", true, 0, 0},
+		{http.StatusTooManyRequests, date, true, 59 * time.Minute, time.Hour},
+		{http.StatusTooManyRequests, "soon", true, -1, -1},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: make(http.Header)}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		d, limited := retryAfter(resp)
+		if limited != tt.limited || d < tt.min || d > tt.max {
+			t.Errorf("retryAfter(%d, %q): want %v in [%v, %v], got %v, %v", tt.status, tt.header, tt.limited, tt.min, tt.max, limited, d)
+		}
+	}
+}
+
 func getwd(t *testing.T) string {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -159,3 +399,147 @@ func getwd(t *testing.T) string {
 	}
 	return cwd
 }
+
+func TestParsePackageImportsSymlinks(t *testing.T) {
+	root := mktemp(t)
+	defer RemoveAll(root)
+
+	write := func(path, src string) {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a/a.go", `package a; import _ "github.com/foo/a"`)
+	write("c/b/b.go", `package b; import _ "github.com/foo/b"`)
+	if err := os.Symlink(filepath.Join(root, "c", "b"), filepath.Join(root, "a", "b")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	// a loop back to the top of the tree
+	if err := os.Symlink(root, filepath.Join(root, "a", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(f bool) { FollowSymlinks = f }(FollowSymlinks)
+	for _, tt := range []struct {
+		follow bool
+		want   map[string]map[string]bool
+	}{{
+		follow: false,
+		want: map[string]map[string]bool{
+			"a":   set("github.com/foo/a"),
+			"c/b": set("github.com/foo/b"),
+		},
+	}, {
+		follow: true,
+		want: map[string]map[string]bool{
+			"a":   set("github.com/foo/a"),
+			"a/b": set("github.com/foo/b"),
+		},
+	}} {
+		FollowSymlinks = tt.follow
+		got, err := ParsePackageImports(root, false)
+		if err != nil {
+			t.Fatalf("ParsePackageImports(follow %v): %v", tt.follow, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePackageImports(follow %v): want %v, got %v", tt.follow, tt.want, got)
+		}
+	}
+}
+
+func TestVisitedDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "a"), filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	var visited visitedDirs
+	for _, tt := range []struct {
+		dir  string
+		want bool
+	}{{"a", false}, {"b", false}, {"link", true}, {"b", true}} {
+		fi, err := os.Stat(filepath.Join(root, tt.dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := visited.visit(fi); got != tt.want {
+			t.Errorf("visit(%s): want %v, got %v", tt.dir, tt.want, got)
+		}
+	}
+}
+
+func TestParseImportsSkipParseError(t *testing.T) {
+	root := mktemp(t)
+	defer RemoveAll(root)
+	for name, src := range map[string]string{
+		"good.go": `package a; import _ "github.com/foo/a"`,
+		"bad.go":  `package a; import (`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := ParseImports(root, false); err == nil {
+		t.Fatalf("ParseImports: expected an error parsing bad.go")
+	}
+
+	defer func() { SkipParseError = nil }()
+	var skipped []string
+	SkipParseError = func(path string, err error) { skipped = append(skipped, path) }
+	got, err := ParseImports(root, false)
+	if err != nil {
+		t.Fatalf("ParseImports: %v", err)
+	}
+	if want := set("github.com/foo/a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseImports: want %v, got %v", want, got)
+	}
+	if want := []string{filepath.Join(root, "bad.go")}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped: want %v, got %v", want, skipped)
+	}
+}
+
+func TestParseImportsError(t *testing.T) {
+	root := mktemp(t)
+	defer RemoveAll(root)
+	bad := filepath.Join(root, "bad.go")
+	if err := ioutil.WriteFile(bad, []byte("package a\n\nimport (\n\t\"fmt\"\n\tfunc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ParseImports(root, false)
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("ParseImports: expected a *ParseError, got %T: %v", err, err)
+	}
+	if perr.Path != bad || perr.Line != 5 {
+		t.Errorf("ParseImports: want an error at %s:5, got %s:%d", bad, perr.Path, perr.Line)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, bad+":5:") {
+		t.Errorf("ParseImports: error %q does not start with the position", msg)
+	}
+}
+
+func TestMetadataTransport(t *testing.T) {
+	def := http.DefaultTransport.(*http.Transport)
+	if metadataTransport == def {
+		t.Fatal("want a copy of http.DefaultTransport")
+	}
+	if metadataTransport.Proxy == nil {
+		t.Error("want a proxy")
+	}
+	if metadataTransport.TLSHandshakeTimeout != def.TLSHandshakeTimeout || metadataTransport.DialContext == nil {
+		t.Errorf("want the timeouts of http.DefaultTransport, got TLS handshake timeout %v", metadataTransport.TLSHandshakeTimeout)
+	}
+	if !metadataTransport.ForceAttemptHTTP2 {
+		t.Error("want HTTP/2 support")
+	}
+}
@@ -0,0 +1,92 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// UnknownLicense is returned by DetectLicense for a license file whose
+// license couldn't be identified.
+const UnknownLicense = "unknown"
+
+// licenses maps phrases found in license texts to SPDX identifiers. More
+// specific phrases come first, as the GPL one is also found in the LGPL.
+var licenses = []struct {
+	phrases []string
+	id      string
+}{
+	{[]string{"apache license", "version 2.0"}, "Apache-2.0"},
+	{[]string{"mozilla public license", "2.0"}, "MPL-2.0"},
+	{[]string{"eclipse public license", "2.0"}, "EPL-2.0"},
+	{[]string{"eclipse public license"}, "EPL-1.0"},
+	{[]string{"gnu affero general public license"}, "AGPL-3.0"},
+	{[]string{"gnu lesser general public license", "version 3"}, "LGPL-3.0"},
+	{[]string{"gnu lesser general public license"}, "LGPL-2.1"},
+	{[]string{"gnu library general public license"}, "LGPL-2.0"},
+	{[]string{"gnu general public license", "version 3"}, "GPL-3.0"},
+	{[]string{"gnu general public license"}, "GPL-2.0"},
+	{[]string{"redistribution and use in source and binary forms", "neither the name"}, "BSD-3-Clause"},
+	{[]string{"redistribution and use in source and binary forms", "names of its contributors"}, "BSD-3-Clause"},
+	{[]string{"redistribution and use in source and binary forms"}, "BSD-2-Clause"},
+	{[]string{"permission is hereby granted, free of charge"}, "MIT"},
+	{[]string{"permission to use, copy, modify, and/or distribute this software for any purpose"}, "ISC"},
+	{[]string{"isc license"}, "ISC"},
+	{[]string{"free and unencumbered software released into the public domain"}, "Unlicense"},
+	{[]string{"creative commons", "cc0"}, "CC0-1.0"},
+}
+
+// DetectLicense looks for a license, copying or notice file in dir and
+// identifies its license with a simple heuristic. It returns the name of
+// the file and the SPDX identifier of the license, or UnknownLicense. file
+// is blank if dir has no such file.
+func DetectLicense(dir string) (file, license string, err error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() && isLegalFile(info.Name()) {
+			names = append(names, info.Name())
+		}
+	}
+	// NOTICE files rarely contain the license text
+	sort.SliceStable(names, func(i, j int) bool {
+		return !isNotice(names[i]) && isNotice(names[j])
+	})
+
+	for _, name := range names {
+		buf, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", "", err
+		}
+		if id := identifyLicense(string(buf)); id != UnknownLicense {
+			return name, id, nil
+		}
+	}
+	if len(names) > 0 {
+		return names[0], UnknownLicense, nil
+	}
+	return "", "", nil
+}
+
+func isNotice(name string) bool {
+	return strings.HasPrefix(strings.ToUpper(name), "NOTICE")
+}
+
+func identifyLicense(text string) string {
+	// normalize case and whitespace, license texts are often rewrapped
+	text = strings.Join(strings.Fields(strings.ToLower(text)), " ")
+next:
+	for _, l := range licenses {
+		for _, p := range l.phrases {
+			if !strings.Contains(text, p) {
+				continue next
+			}
+		}
+		return l.id
+	}
+	return UnknownLicense
+}
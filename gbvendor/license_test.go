@@ -0,0 +1,48 @@
+package vendor
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentifyLicense(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Permission is hereby granted, free of charge, to any person obtaining a copy", "MIT"},
+		{"Apache License\n    Version 2.0, January 2004", "Apache-2.0"},
+		{"Redistribution and use in source and binary forms, with or without\nmodification... Neither the name of Google Inc.", "BSD-3-Clause"},
+		{"Redistribution and use in source and binary forms, with or without modification", "BSD-2-Clause"},
+		{"GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007", "GPL-3.0"},
+		{"GNU GENERAL PUBLIC LICENSE\nVersion 2, June 1991", "GPL-2.0"},
+		{"GNU LESSER GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007", "LGPL-3.0"},
+		{"Mozilla Public License Version 2.0", "MPL-2.0"},
+		{"Permission to use, copy, modify, and/or distribute this software for any\npurpose with or without fee is hereby granted", "ISC"},
+		{"All rights reserved.", UnknownLicense},
+	}
+	for _, tt := range tests {
+		if got := identifyLicense(tt.text); got != tt.want {
+			t.Errorf("identifyLicense(%q): want %q, got %q", tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestDetectLicense(t *testing.T) {
+	dir := mktemp(t)
+	defer RemoveAll(dir)
+
+	file, license, err := DetectLicense(dir)
+	if err != nil || file != "" {
+		t.Fatalf("DetectLicense: want no file, got %q, %v", file, err)
+	}
+
+	writeFile(t, filepath.Join(dir, "NOTICE"), "Copyright Foo")
+	writeFile(t, filepath.Join(dir, "LICENSE.txt"), "Permission is hereby granted, free of charge")
+	file, license, err = DetectLicense(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != "LICENSE.txt" || license != "MIT" {
+		t.Fatalf("DetectLicense: want LICENSE.txt, MIT, got %q, %q", file, license)
+	}
+}
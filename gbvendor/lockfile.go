@@ -0,0 +1,267 @@
+package vendor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LockedDependency is a repository pinned by the lock file of another
+// vendoring tool.
+type LockedDependency struct {
+	// Importpath is the import path of the root of the repository, or of
+	// one of its packages if the lock file doesn't record the root.
+	Importpath string
+
+	// Revision is the pinned revision.
+	Revision string
+
+	// Packages are the packages of the repository in use, relative to
+	// Importpath. "." is the root package.
+	Packages []string
+
+	// Test is true if the repository is only needed by tests.
+	Test bool
+}
+
+// ParseGopkgLock parses the projects of a dep Gopkg.lock file. Only the
+// subset of TOML used by dep is supported.
+func ParseGopkgLock(r io.Reader) ([]LockedDependency, error) {
+	var (
+		deps []LockedDependency
+		cur  *LockedDependency
+		key  string   // key of a multi line array being read
+		arr  []string // values of that array
+	)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if key != "" {
+			if line == "]" {
+				if cur != nil && key == "packages" {
+					cur.Packages = arr
+				}
+				key, arr = "", nil
+				continue
+			}
+			v, err := tomlString(strings.TrimSuffix(line, ","))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			arr = append(arr, v)
+			continue
+		}
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line == "[[projects]]":
+			deps = append(deps, LockedDependency{})
+			cur = &deps[len(deps)-1]
+			continue
+		case strings.HasPrefix(line, "["):
+			// any other table, like solve-meta
+			cur = nil
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		k, v := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if cur == nil {
+			if v == "[" {
+				key = k
+			}
+			continue
+		}
+		switch {
+		case v == "[":
+			key = k
+		case strings.HasPrefix(v, "["):
+			vs, err := tomlArray(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			if k == "packages" {
+				cur.Packages = vs
+			}
+		case k == "name" || k == "revision":
+			s, err := tomlString(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			if k == "name" {
+				cur.Importpath = s
+			} else {
+				cur.Revision = s
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for _, d := range deps {
+		if d.Importpath == "" || d.Revision == "" {
+			return nil, fmt.Errorf("project %q without a name or revision", d.Importpath)
+		}
+	}
+	return deps, nil
+}
+
+func tomlString(s string) (string, error) {
+	v, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	return v, nil
+}
+
+// tomlArray parses a single line array of strings.
+func tomlArray(s string) ([]string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("invalid array %s", s)
+	}
+	var vs []string
+	for _, f := range strings.Split(s[1:len(s)-1], ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		v, err := tomlString(f)
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+// ParseGlideLock parses the imports and test imports of a glide.lock
+// file. Only the subset of YAML written by glide is supported.
+func ParseGlideLock(r io.Reader) ([]LockedDependency, error) {
+	var (
+		deps    []LockedDependency
+		test    bool // reading testImports
+		inList  bool // reading imports or testImports
+		subpkgs bool // reading the subpackages of the last dependency
+	)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		text := sc.Text()
+		line := strings.TrimSpace(text)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// top level keys
+		if !strings.HasPrefix(text, " ") && !strings.HasPrefix(text, "-") {
+			subpkgs = false
+			switch {
+			case strings.HasPrefix(line, "imports:"):
+				inList, test = true, false
+			case strings.HasPrefix(line, "testImports:"):
+				inList, test = true, true
+			default:
+				inList = false
+			}
+			continue
+		}
+		if !inList {
+			continue
+		}
+
+		if subpkgs && strings.HasPrefix(text, "  ") && strings.HasPrefix(line, "- ") {
+			last := &deps[len(deps)-1]
+			last.Packages = append(last.Packages, yamlString(line[2:]))
+			continue
+		}
+		if strings.HasPrefix(line, "- ") {
+			// a new dependency, its first key follows the dash
+			deps = append(deps, LockedDependency{Test: test})
+			subpkgs = false
+			line = strings.TrimSpace(line[2:])
+		}
+		if len(deps) == 0 {
+			return nil, fmt.Errorf("line %d: expected a list item", n)
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key: value", n)
+		}
+		k, v := line[:i], yamlString(line[i+1:])
+		last := &deps[len(deps)-1]
+		subpkgs = false
+		switch k {
+		case "name":
+			last.Importpath = v
+		case "version":
+			last.Revision = v
+		case "subpackages":
+			subpkgs = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for i, d := range deps {
+		if d.Importpath == "" || d.Revision == "" {
+			return nil, fmt.Errorf("import %q without a name or version", d.Importpath)
+		}
+		if len(d.Packages) == 0 {
+			deps[i].Packages = []string{"."}
+		}
+	}
+	return deps, nil
+}
+
+// yamlString returns the plain or quoted scalar s.
+func yamlString(s string) string {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.Unquote(s); err == nil {
+		return v
+	}
+	return strings.Trim(s, "'")
+}
+
+// ParseGodeps parses the dependencies of a godep Godeps.json file. godep
+// records packages rather than repositories, so packages at the same
+// revision below another one are merged into its entry.
+func ParseGodeps(r io.Reader) ([]LockedDependency, error) {
+	var godeps struct {
+		Deps []struct {
+			ImportPath string
+			Rev        string
+		}
+	}
+	if err := json.NewDecoder(r).Decode(&godeps); err != nil {
+		return nil, err
+	}
+	sort.Slice(godeps.Deps, func(i, j int) bool {
+		return godeps.Deps[i].ImportPath < godeps.Deps[j].ImportPath
+	})
+
+	var deps []LockedDependency
+	for _, d := range godeps.Deps {
+		if d.ImportPath == "" || d.Rev == "" {
+			return nil, fmt.Errorf("dependency %q without an import path or revision", d.ImportPath)
+		}
+		if n := len(deps); n > 0 {
+			last := &deps[n-1]
+			if strings.HasPrefix(d.ImportPath, last.Importpath+"/") && d.Rev == last.Revision {
+				last.Packages = append(last.Packages, d.ImportPath[len(last.Importpath)+1:])
+				continue
+			}
+		}
+		deps = append(deps, LockedDependency{
+			Importpath: d.ImportPath,
+			Revision:   d.Rev,
+			Packages:   []string{"."},
+		})
+	}
+	return deps, nil
+}
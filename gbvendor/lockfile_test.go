@@ -0,0 +1,146 @@
+package vendor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGopkgLock(t *testing.T) {
+	const lock = `# This file is autogenerated, do not edit; changes may be undone by the next 'dep ensure'.
+
+
+[[projects]]
+  digest = "1:cf3e13b8fa8da13a5ac2bb1a8e9e7b2d2c35e05441347bca4d3e1a6b9e9d60a1"
+  name = "github.com/pkg/errors"
+  packages = ["."]
+  pruneopts = "UT"
+  revision = "645ef00459ed84a119197bfb8d8205042c6df63d"
+  version = "v0.8.0"
+
+[[projects]]
+  branch = "master"
+  name = "golang.org/x/net"
+  packages = [
+    "context",
+    "http2",
+  ]
+  revision = "1e491301e022f8f977054da4c2d852decd59571f"
+
+[solve-meta]
+  analyzer-name = "dep"
+  input-imports = [
+    "github.com/pkg/errors",
+  ]
+  solver-version = 1
+`
+	got, err := ParseGopkgLock(strings.NewReader(lock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LockedDependency{{
+		Importpath: "github.com/pkg/errors",
+		Revision:   "645ef00459ed84a119197bfb8d8205042c6df63d",
+		Packages:   []string{"."},
+	}, {
+		Importpath: "golang.org/x/net",
+		Revision:   "1e491301e022f8f977054da4c2d852decd59571f",
+		Packages:   []string{"context", "http2"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseGopkgLock: want %+v, got %+v", want, got)
+	}
+
+	if _, err := ParseGopkgLock(strings.NewReader("[[projects]]\n  name = \"github.com/foo/bar\"\n")); err == nil {
+		t.Fatal("ParseGopkgLock: expected error for a project without revision")
+	}
+}
+
+func TestParseGlideLock(t *testing.T) {
+	const lock = `hash: 2f7e6a4f3b1b5c4a1c5b7a9c3a2a3b9c1f9b2a8c7d6e5f4a3b2c1d0e9f8a7b6c
+updated: 2017-06-01T12:00:00.000000000+02:00
+imports:
+- name: github.com/foo/bar
+  version: 1e491301e022f8f977054da4c2d852decd59571f
+  subpackages:
+  - baz
+  - qux/quux
+- name: gopkg.in/yaml.v2
+  version: "a5b47d31c556af34a302ce5d659e6fea44d90de0"
+  repo: https://github.com/go-yaml/yaml
+  vcs: git
+testImports:
+- name: github.com/stretchr/testify
+  version: 69483b4bd14f5845b5a1e55bca19e954e827f1d0
+  subpackages:
+  - assert
+`
+	got, err := ParseGlideLock(strings.NewReader(lock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LockedDependency{{
+		Importpath: "github.com/foo/bar",
+		Revision:   "1e491301e022f8f977054da4c2d852decd59571f",
+		Packages:   []string{"baz", "qux/quux"},
+	}, {
+		Importpath: "gopkg.in/yaml.v2",
+		Revision:   "a5b47d31c556af34a302ce5d659e6fea44d90de0",
+		Packages:   []string{"."},
+	}, {
+		Importpath: "github.com/stretchr/testify",
+		Revision:   "69483b4bd14f5845b5a1e55bca19e954e827f1d0",
+		Packages:   []string{"assert"},
+		Test:       true,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseGlideLock: want %+v, got %+v", want, got)
+	}
+}
+
+func TestParseGodeps(t *testing.T) {
+	const godeps = `{
+	"ImportPath": "github.com/me/proj",
+	"GoVersion": "go1.8",
+	"Deps": [
+		{
+			"ImportPath": "golang.org/x/net/http2",
+			"Rev": "1e491301e022f8f977054da4c2d852decd59571f"
+		},
+		{
+			"ImportPath": "github.com/foo/bar",
+			"Comment": "v1.0.0",
+			"Rev": "645ef00459ed84a119197bfb8d8205042c6df63d"
+		},
+		{
+			"ImportPath": "github.com/foo/bar/baz",
+			"Rev": "645ef00459ed84a119197bfb8d8205042c6df63d"
+		},
+		{
+			"ImportPath": "golang.org/x/net/context",
+			"Rev": "1e491301e022f8f977054da4c2d852decd59571f"
+		}
+	]
+}`
+	got, err := ParseGodeps(strings.NewReader(godeps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LockedDependency{{
+		Importpath: "github.com/foo/bar",
+		Revision:   "645ef00459ed84a119197bfb8d8205042c6df63d",
+		Packages:   []string{".", "baz"},
+	}, {
+		// the root isn't known, fetching either vendors the repository
+		Importpath: "golang.org/x/net/context",
+		Revision:   "1e491301e022f8f977054da4c2d852decd59571f",
+		Packages:   []string{"."},
+	}, {
+		Importpath: "golang.org/x/net/http2",
+		Revision:   "1e491301e022f8f977054da4c2d852decd59571f",
+		Packages:   []string{"."},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseGodeps: want %+v, got %+v", want, got)
+	}
+}
@@ -5,16 +5,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
 )
 
 // gb-vendor manifest support
 
+// ManifestVersion is the latest version of the manifest format. Version 1
+// added the fields after Path to Dependency. WriteManifest writes version 0
+// if no dependency uses them, so that older versions of gvt can still read
+// the manifest. ReadManifest rejects manifests of later versions, which
+// could lose information if rewritten.
+const ManifestVersion = 1
+
 // Manifest describes the layout of $PROJECT/vendor/manifest.
 type Manifest struct {
-	// Manifest version. Current manifest version is 0.
+	// Manifest version. Current manifest version is ManifestVersion.
 	Version int `json:"version"`
 
 	// Depenencies is a list of vendored dependencies.
@@ -43,10 +53,22 @@ func (m *Manifest) RemoveDependency(dep Dependency) error {
 	return fmt.Errorf("dependency does not exist")
 }
 
-// HasImportpath reports whether the Manifest contains the import path.
+// HasImportpath reports whether the Manifest contains the import path,
+// either as a dependency or as a package inside one. A major version
+// suffix, as in "/v2", is not considered a package inside a dependency,
+// as it's usually vendored separately.
 func (m *Manifest) HasImportpath(path string) bool {
-	_, err := m.GetDependencyForImportpath(path)
-	return err == nil
+	for _, d := range m.Dependencies {
+		if path == d.Importpath {
+			return true
+		}
+		if strings.HasPrefix(path, d.Importpath+"/") {
+			if major, _ := SplitMajorVersion(path[len(d.Importpath):]); major == "" {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // GetDependencyForRepository return a dependency for specified URL
@@ -82,6 +104,54 @@ type Dependency struct {
 	// Path is the path inside the Repository where the
 	// dependency was fetched from.
 	Path string `json:"path,omitempty"`
+
+	// Checksum is the TreeChecksum of the vendored files, excluding
+	// the ones of other dependencies nested inside this one.
+	// Can be blank for dependencies vendored by older versions.
+	Checksum string `json:"checksum,omitempty"`
+
+	// Pruned is true if test files, testdata directories and
+	// documentation were removed from the vendored files with PruneFiles.
+	Pruned bool `json:"pruned,omitempty"`
+
+	// Transitive is true if the dependency was fetched because another
+	// dependency imports it, rather than asked for. Dependencies vendored
+	// by older versions are considered direct.
+	Transitive bool `json:"transitive,omitempty"`
+
+	// Test is true if the dependency was fetched recursively only because
+	// the tests of another dependency import it.
+	Test bool `json:"test,omitempty"`
+
+	// Shallow is true if the dependency was checked out without its
+	// history, with ShallowClone.
+	Shallow bool `json:"shallow,omitempty"`
+
+	// Replace is the local directory the dependency was copied from
+	// instead of being fetched, for local development. Repository,
+	// Revision and Checksum are then blank.
+	Replace string `json:"replace,omitempty"`
+
+	// Symlink is true if the vendored copy of a replaced dependency is a
+	// symlink to the Replace directory.
+	Symlink bool `json:"symlink,omitempty"`
+
+	// Packages are the import paths of the packages kept by fetch
+	// -minimal, the ones the project imports. If empty, all the packages
+	// of the dependency are vendored.
+	Packages []string `json:"packages,omitempty"`
+
+	// Flattened is true if the packages in the vendor directory of the
+	// dependency were moved to the top-level vendor directory, see Hoisted.
+	Flattened bool `json:"flattened,omitempty"`
+
+	// Hoisted is the import path of the dependency whose vendor directory
+	// this one was moved out of. Repository and Revision are then blank.
+	Hoisted string `json:"hoisted,omitempty"`
+
+	// FetchedAt is when the dependency was last fetched or updated, in
+	// RFC 3339 format. Blank for dependencies vendored by older versions.
+	FetchedAt string `json:"fetchedAt,omitempty"`
 }
 
 // WriteManifest writes a Manifest to the path. If the manifest does
@@ -89,9 +159,14 @@ type Dependency struct {
 // If the manifest file is empty (0 dependencies) it will be deleted.
 // The dependencies will be ordered by import path to reduce churn when making
 // changes.
-// TODO(dfc) write to temporary file and move atomically to avoid
-// destroying a working vendorfile.
+// The manifest is written to a temporary file in the same directory which is
+// then renamed over path, so an interrupted write never leaves a truncated
+// manifest behind.
 func WriteManifest(path string, m *Manifest) error {
+	return manifestError(path, writeManifestFile(path, m))
+}
+
+func writeManifestFile(path string, m *Manifest) error {
 	if len(m.Dependencies) == 0 {
 		err := os.Remove(path)
 		if !os.IsNotExist(err) {
@@ -100,18 +175,49 @@ func WriteManifest(path string, m *Manifest) error {
 		return nil
 	}
 
-	f, err := os.Create(path)
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
 	if err != nil {
 		return err
 	}
+	tmp := f.Name()
+	// TempFile creates the file 0600, match what os.Create would do
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
 	if err := writeManifest(f, m); err != nil {
 		f.Close()
+		os.Remove(tmp)
 		return err
 	}
-	return f.Close()
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// rename is replaced in tests to simulate a failure before the new manifest
+// is put in place.
+var rename = os.Rename
+
+// EncodeManifest writes m to w as WriteManifest would write it to a file.
+func EncodeManifest(w io.Writer, m *Manifest) error {
+	return writeManifest(w, m)
 }
 
 func writeManifest(w io.Writer, m *Manifest) error {
+	m.Version = manifestVersion(m)
 	sort.Sort(byImportpath(m.Dependencies))
 	buf, err := json.MarshalIndent(m, "", "\t")
 	if err != nil {
@@ -129,17 +235,73 @@ func ReadManifest(path string) (*Manifest, error) {
 		if os.IsNotExist(err) {
 			return new(Manifest), nil
 		}
-		return nil, err
+		return nil, manifestError(path, err)
 	}
 	defer f.Close()
-	return readManifest(f)
+	m, err := readManifest(f)
+	return m, manifestError(path, err)
+}
+
+// ManifestError is an error reading or writing the manifest at Path.
+type ManifestError struct {
+	Path string
+	Err  error
+}
+
+func (e *ManifestError) Error() string {
+	// don't repeat the path of the errors of the os package
+	if pe, ok := e.Err.(*os.PathError); ok && pe.Path == e.Path {
+		return fmt.Sprintf("%s: %s: %v", e.Path, pe.Op, pe.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *ManifestError) Unwrap() error { return e.Err }
+
+// manifestError returns err, if not nil, as a *ManifestError.
+func manifestError(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ManifestError{Path: path, Err: err}
+}
+
+// manifestVersion returns the oldest version of the manifest format which
+// can hold the dependencies of m.
+func manifestVersion(m *Manifest) int {
+	for _, d := range m.Dependencies {
+		v0 := Dependency{
+			Importpath: d.Importpath,
+			Repository: d.Repository,
+			Revision:   d.Revision,
+			Branch:     d.Branch,
+			Path:       d.Path,
+		}
+		if len(d.Packages) == 0 {
+			d.Packages = nil
+		}
+		if !reflect.DeepEqual(d, v0) {
+			return ManifestVersion
+		}
+	}
+	return 0
 }
 
 func readManifest(r io.Reader) (*Manifest, error) {
+	return decodeManifest(r, ManifestVersion)
+}
+
+// decodeManifest reads a manifest of at most version supported.
+func decodeManifest(r io.Reader, supported int) (*Manifest, error) {
 	var m Manifest
 	d := json.NewDecoder(r)
-	err := d.Decode(&m)
-	return &m, err
+	if err := d.Decode(&m); err != nil {
+		return &m, err
+	}
+	if m.Version > supported {
+		return &m, fmt.Errorf("manifest version %d is newer than version %d supported by this gvt, please upgrade", m.Version, supported)
+	}
+	return &m, nil
 }
 
 type byImportpath []Dependency
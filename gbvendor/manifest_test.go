@@ -2,8 +2,13 @@ package vendor
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -72,6 +77,49 @@ func TestManifest(t *testing.T) {
 	assertNotExists(t, mf)
 }
 
+func TestWriteManifestInterrupted(t *testing.T) {
+	root := mktemp(t)
+	defer RemoveAll(root)
+
+	mf := filepath.Join(root, "manifest")
+	m := &Manifest{Dependencies: []Dependency{{
+		Importpath: "github.com/foo/bar",
+		Repository: "https://github.com/foo/bar",
+		Revision:   "cafebad",
+		Branch:     "master",
+	}}}
+	if err := WriteManifest(mf, m); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+
+	// fail between writing the new manifest and moving it in place
+	defer func(r func(string, string) error) { rename = r }(rename)
+	rename = func(oldpath, newpath string) error {
+		return errors.New("interrupted")
+	}
+	m.Dependencies[0].Revision = "deadbeef"
+	if err := WriteManifest(mf, m); err == nil {
+		t.Fatal("WriteManifest: expected error")
+	}
+
+	got, err := ReadManifest(mf)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if len(got.Dependencies) != 1 || got.Dependencies[0].Revision != "cafebad" {
+		t.Fatalf("expected the old manifest to survive, got %+v", got.Dependencies)
+	}
+
+	// the temporary file must not be left behind
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected only the manifest in %s, got %d files", root, len(files))
+	}
+}
+
 func TestEmptyPathIsNotWritten(t *testing.T) {
 	m := Manifest{
 		Version: 0,
@@ -102,3 +150,209 @@ func TestEmptyPathIsNotWritten(t *testing.T) {
 		t.Fatalf("want: %s, got %s", want, got)
 	}
 }
+
+func TestHasImportpath(t *testing.T) {
+	m := Manifest{
+		Dependencies: []Dependency{{
+			Importpath: "github.com/foo/bar",
+		}, {
+			Importpath: "golang.org/x/net/context",
+		}},
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"github.com/foo/bar", true},
+		{"github.com/foo/bar/baz", true},
+		{"github.com/foo/barbaz", false},
+		{"github.com/foo", false},
+		{"golang.org/x/net/context/ctxhttp", true},
+		{"golang.org/x/net/http2", false},
+		{"github.com/foo/bar/v2", false},
+		{"github.com/foo/bar/v2/baz", false},
+		{"github.com/foo/bar/v1", true},
+		{"github.com/foo/bar/vendor", true},
+	}
+	for _, tt := range tests {
+		if got := m.HasImportpath(tt.path); got != tt.want {
+			t.Errorf("HasImportpath(%q): want %v, got %v", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestManifestTransitive(t *testing.T) {
+	// manifests written before the field existed only have direct dependencies
+	old := `{"version": 0, "dependencies": [{"importpath": "github.com/foo/bar", "repository": "https://github.com/foo/bar", "revision": "cafebad", "branch": "master"}]}`
+	m, err := readManifest(bytes.NewBufferString(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Dependencies[0].Transitive {
+		t.Fatalf("expected a dependency of an old manifest to be direct")
+	}
+
+	m.Dependencies = append(m.Dependencies, Dependency{
+		Importpath: "github.com/quux/flobble",
+		Repository: "https://github.com/quux/flobble",
+		Revision:   "deadbeef",
+		Branch:     "master",
+		Transitive: true,
+	})
+	var buf bytes.Buffer
+	if err := writeManifest(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte(`"transitive"`)); n != 1 {
+		t.Fatalf("expected the field to be written only for the transitive dependency, found it %d times", n)
+	}
+	m, err = readManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Dependencies[0].Transitive || !m.Dependencies[1].Transitive {
+		t.Fatalf("transitive field not preserved: %+v", m.Dependencies)
+	}
+}
+
+func TestManifestFetchedAt(t *testing.T) {
+	old := `{"version": 0, "dependencies": [{"importpath": "github.com/foo/bar", "repository": "https://github.com/foo/bar", "revision": "cafebad", "branch": "master"}]}`
+	m, err := readManifest(bytes.NewBufferString(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Dependencies[0].FetchedAt != "" {
+		t.Fatalf("expected no fetch time for a dependency of an old manifest, got %q", m.Dependencies[0].FetchedAt)
+	}
+
+	m.Dependencies[0].FetchedAt = "2016-01-02T15:04:05Z"
+	var buf bytes.Buffer
+	if err := writeManifest(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	m, err = readManifest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Dependencies[0].FetchedAt; got != "2016-01-02T15:04:05Z" {
+		t.Fatalf("fetchedAt field not preserved, got %q", got)
+	}
+}
+
+func TestReadManifestNewerVersion(t *testing.T) {
+	newer := fmt.Sprintf(`{"version": %d, "dependencies": []}`, ManifestVersion+1)
+	if _, err := readManifest(bytes.NewBufferString(newer)); err == nil {
+		t.Fatalf("expected an error reading a manifest of version %d", ManifestVersion+1)
+	}
+
+	current := fmt.Sprintf(`{"version": %d, "dependencies": []}`, ManifestVersion)
+	if _, err := readManifest(bytes.NewBufferString(current)); err != nil {
+		t.Fatalf("reading a manifest of version %d: %v", ManifestVersion, err)
+	}
+}
+
+func TestManifestError(t *testing.T) {
+	root := mktemp(t)
+	defer RemoveAll(root)
+	m := &Manifest{Dependencies: []Dependency{
+		{Importpath: "github.com/foo/bar", Repository: "https://github.com/foo/bar", Revision: "cafebad", Branch: "master"},
+	}}
+
+	tests := []struct {
+		name string
+		f    func(mf string) error
+	}{
+		{"invalid", func(mf string) error {
+			if err := ioutil.WriteFile(mf, []byte("{"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadManifest(mf)
+			return err
+		}},
+		{"newer", func(mf string) error {
+			newer := fmt.Sprintf(`{"version": %d, "dependencies": []}`, ManifestVersion+1)
+			if err := ioutil.WriteFile(mf, []byte(newer), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadManifest(mf)
+			return err
+		}},
+		{"directory", func(mf string) error {
+			if err := mkdir(mf); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadManifest(mf)
+			return err
+		}},
+		{"missing-dir", func(mf string) error {
+			return WriteManifest(filepath.Join(mf, "missing", "manifest"), m)
+		}},
+		{"interrupted", func(mf string) error {
+			defer func(r func(string, string) error) { rename = r }(rename)
+			rename = func(oldpath, newpath string) error {
+				return errors.New("interrupted")
+			}
+			return WriteManifest(mf, m)
+		}},
+	}
+	for _, tt := range tests {
+		mf := filepath.Join(root, tt.name)
+		err := tt.f(mf)
+		var me *ManifestError
+		if !errors.As(err, &me) {
+			t.Errorf("%s: want a *ManifestError, got %#v", tt.name, err)
+			continue
+		}
+		if !strings.HasPrefix(me.Path, mf) {
+			t.Errorf("%s: want the path %s, got %s", tt.name, mf, me.Path)
+		}
+	}
+
+	// a missing manifest is not an error
+	if _, err := ReadManifest(filepath.Join(root, "absent")); err != nil {
+		t.Fatalf("ReadManifest of a missing manifest: %v", err)
+	}
+}
+
+func TestManifestVersion(t *testing.T) {
+	plain := Dependency{Importpath: "github.com/foo/bar", Repository: "https://github.com/foo/bar", Revision: "cafebad", Branch: "master", Path: "/bar"}
+	replaced := Dependency{Importpath: "github.com/foo/baz", Replace: "/src/baz"}
+	hoisted := Dependency{Importpath: "github.com/foo/quux", Hoisted: "github.com/foo/bar"}
+	minimal := plain
+	minimal.Packages = []string{"github.com/foo/bar/a"}
+
+	tests := []struct {
+		deps    []Dependency
+		version int
+	}{
+		{[]Dependency{plain}, 0},
+		{[]Dependency{plain, replaced}, ManifestVersion},
+		{[]Dependency{hoisted}, ManifestVersion},
+		{[]Dependency{minimal}, ManifestVersion},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeManifest(&buf, &Manifest{Dependencies: tt.deps}); err != nil {
+			t.Fatal(err)
+		}
+		m, err := readManifest(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("readManifest: %v", err)
+		}
+		if m.Version != tt.version {
+			t.Errorf("writeManifest of %+v: want version %d, got %d", tt.deps, tt.version, m.Version)
+		}
+		if !reflect.DeepEqual(m.Dependencies, tt.deps) {
+			t.Errorf("readManifest: want %+v, got %+v", tt.deps, m.Dependencies)
+		}
+
+		// a gvt only supporting version 0 must not rewrite it
+		_, err = decodeManifest(bytes.NewReader(buf.Bytes()), 0)
+		if tt.version > 0 && err == nil {
+			t.Errorf("decodeManifest of %+v: expected a version 0 reader to reject it", tt.deps)
+		}
+		if tt.version == 0 && err != nil {
+			t.Errorf("decodeManifest of %+v: %v", tt.deps, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package vendor
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Mirrors maps prefixes of repository URLs, without their scheme, to the
+// prefix to clone them from instead, as in "github.com" to
+// "git.internal.example.com/mirror". The RemoteRepo URL, and so the
+// manifest, keep the original location. When several prefixes match the
+// longest one is used.
+var Mirrors = make(map[string]string)
+
+// mirrorURL returns rawurl rewritten according to Mirrors. URLs without a
+// scheme, like scp style git ones, are left alone.
+func mirrorURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return rawurl
+	}
+	loc := u.Host + u.Path
+	var prefix string
+	for p := range Mirrors {
+		if (loc == p || strings.HasPrefix(loc, p+"/")) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	if prefix == "" {
+		return rawurl
+	}
+	loc = Mirrors[prefix] + loc[len(prefix):]
+	if i := strings.Index(loc, "/"); i >= 0 {
+		u.Host, u.Path = loc[:i], loc[i:]
+	} else {
+		u.Host, u.Path = loc, ""
+	}
+	u.RawPath = ""
+	Debugf("cloning %s from mirror %s", rawurl, u)
+	return u.String()
+}
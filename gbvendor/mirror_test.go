@@ -0,0 +1,29 @@
+package vendor
+
+import "testing"
+
+func TestMirrorURL(t *testing.T) {
+	defer func(m map[string]string) { Mirrors = m }(Mirrors)
+	Mirrors = map[string]string{
+		"github.com":         "git.internal.example.com/mirror",
+		"github.com/foo/bar": "git.internal.example.com/bar",
+		"example.com":        "git.internal.example.com",
+	}
+	tests := []struct {
+		url, want string
+	}{
+		{"https://github.com/pkg/errors", "https://git.internal.example.com/mirror/pkg/errors"},
+		{"ssh://git@github.com/pkg/errors", "ssh://git@git.internal.example.com/mirror/pkg/errors"},
+		{"https://github.com/foo/bar", "https://git.internal.example.com/bar"},
+		{"https://github.com/foo/barbaz", "https://git.internal.example.com/mirror/foo/barbaz"},
+		{"https://example.com:8443/x", "https://example.com:8443/x"},
+		{"https://example.com/x", "https://git.internal.example.com/x"},
+		{"https://example.community/x", "https://example.community/x"},
+		{"git@github.com:pkg/errors", "git@github.com:pkg/errors"},
+	}
+	for _, tt := range tests {
+		if got := mirrorURL(tt.url); got != tt.want {
+			t.Errorf("mirrorURL(%q): want %q, got %q", tt.url, tt.want, got)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package vendor
 
 import (
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strings"
 )
 
 // RemoveAll removes path and any children it contains. Unlike os.RemoveAll it
@@ -30,3 +34,98 @@ func RemoveAll(path string) error {
 	}
 	return os.RemoveAll(path)
 }
+
+// importPath returns the import path of the directory rel, relative to the
+// root of a tree vendored as prefix. Both may use the separator of the OS or
+// backslashes, which import paths never contain, as on Windows.
+func importPath(prefix, rel string) string {
+	return path.Join(slashPath(prefix), slashPath(rel))
+}
+
+// slashPath returns p with its separators replaced by slashes.
+func slashPath(p string) string {
+	return strings.Replace(filepath.ToSlash(p), `\`, "/", -1)
+}
+
+var majorre = regexp.MustCompile(`^/v([2-9]|[1-9][0-9]+)$`)
+
+// SplitMajorVersion splits a path inside a repository, as returned by
+// DeduceRemoteRepo, into its leading major version suffix of at least 2,
+// as in "/v2", and the rest. major is blank if there is no such suffix.
+// gopkg.in style versions, as in "yaml.v2", are part of the repository
+// root and are never split.
+func SplitMajorVersion(extra string) (major, rest string) {
+	if !strings.HasPrefix(extra, "/") {
+		return "", extra
+	}
+	i := strings.Index(extra[1:], "/") + 1
+	if i == 0 {
+		i = len(extra)
+	}
+	if !majorre.MatchString(extra[:i]) {
+		return "", extra
+	}
+	return extra[:i], extra[i:]
+}
+
+// CheckImportPath reports whether path is a valid import path, following
+// the rules of the go command: it is made of non-empty elements separated
+// by single slashes, without a leading or trailing one, each made of ASCII
+// letters, digits and the punctuation -._~+, not starting or ending
+// with a dot, and not a name reserved by Windows like "con".
+func CheckImportPath(path string) error {
+	if path == "" {
+		return fmt.Errorf("malformed import path %q: empty string", path)
+	}
+	if strings.HasPrefix(path, "/") {
+		return fmt.Errorf("malformed import path %q: leading slash", path)
+	}
+	if strings.HasSuffix(path, "/") {
+		return fmt.Errorf("malformed import path %q: trailing slash", path)
+	}
+	for _, elem := range strings.Split(path, "/") {
+		if err := checkElem(elem); err != nil {
+			return fmt.Errorf("malformed import path %q: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func checkElem(elem string) error {
+	if elem == "" {
+		return fmt.Errorf("empty path element")
+	}
+	if elem == "." || elem == ".." {
+		return fmt.Errorf("invalid path element %q", elem)
+	}
+	for _, r := range elem {
+		ok := 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' ||
+			strings.ContainsRune("-._~+", r)
+		if !ok {
+			return fmt.Errorf("invalid char %q", r)
+		}
+	}
+	if elem[0] == '.' {
+		return fmt.Errorf("leading dot in path element")
+	}
+	if elem[len(elem)-1] == '.' {
+		return fmt.Errorf("trailing dot in path element")
+	}
+	short := elem
+	if i := strings.Index(short, "."); i >= 0 {
+		short = short[:i]
+	}
+	for _, bad := range reservedNames {
+		if strings.EqualFold(short, bad) {
+			return fmt.Errorf("%q disallowed as path element component on Windows", short)
+		}
+	}
+	return nil
+}
+
+// reservedNames are the file names Windows reserves for devices.
+var reservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
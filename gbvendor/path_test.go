@@ -5,6 +5,7 @@ package vendor
 
 import (
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 )
@@ -104,3 +105,81 @@ func TestRemoveAll(t *testing.T) {
 		t.Fatalf("Lstat %q succeeded after RemoveAll (final)", path)
 	}
 }
+
+func TestSplitMajorVersion(t *testing.T) {
+	tests := []struct {
+		extra, major, rest string
+	}{
+		{"", "", ""},
+		{"/v2", "/v2", ""},
+		{"/v2/bson", "/v2", "/bson"},
+		{"/v10", "/v10", ""},
+		{"/v10/foo/bar", "/v10", "/foo/bar"},
+		{"/v1", "", "/v1"},
+		{"/v0", "", "/v0"},
+		{"/v02", "", "/v02"},
+		{"/vendor", "", "/vendor"},
+		{"/foo/v2", "", "/foo/v2"},
+		{"/yaml.v2", "", "/yaml.v2"},
+	}
+	for _, tt := range tests {
+		major, rest := SplitMajorVersion(tt.extra)
+		if major != tt.major || rest != tt.rest {
+			t.Errorf("SplitMajorVersion(%q): want %q, %q, got %q, %q", tt.extra, tt.major, tt.rest, major, rest)
+		}
+	}
+}
+
+func TestCheckImportPath(t *testing.T) {
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"github.com/foo/bar", true},
+		{"gopkg.in/yaml.v2", true},
+		{"example.com/a-b_c~d+e/v2", true},
+		{"", false},
+		{"/github.com/foo", false},
+		{"github.com/foo/", false},
+		{"github.com//foo", false},
+		{"github.com/foo/./bar", false},
+		{"github.com/foo/../bar", false},
+		{"github.com/{{.Name}}", false},
+		{"github.com/foo bar", false},
+		{"github.com/foo\\bar", false},
+		{"github.com/.hidden", false},
+		{"github.com/foo./bar", false},
+		{"github.com/foo/con", false},
+		{"github.com/foo/Aux.go", false},
+		{"github.com/foo/console", true},
+		{"github.com/föö", false},
+	}
+	for _, tt := range tests {
+		err := CheckImportPath(tt.path)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("CheckImportPath(%q): want ok %v, got %v", tt.path, tt.ok, err)
+		}
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	tests := []struct {
+		prefix, rel string
+		want        string
+	}{
+		{"", "", ""},
+		{"", "github.com/foo", "github.com/foo"},
+		{"github.com/foo", "bar", "github.com/foo/bar"},
+		{`github.com\foo`, `bar\baz`, "github.com/foo/bar/baz"},
+		{filepath.FromSlash("github.com/foo"), filepath.FromSlash("bar/baz"), "github.com/foo/bar/baz"},
+		{"github.com/foo", "", "github.com/foo"},
+	}
+	for _, tt := range tests {
+		if got := importPath(tt.prefix, tt.rel); got != tt.want {
+			t.Errorf("importPath(%q, %q): want %q, got %q", tt.prefix, tt.rel, tt.want, got)
+		}
+		if got := filepath.ToSlash(filepath.FromSlash(tt.want)); got != tt.want {
+			t.Errorf("%q: does not round-trip through FromSlash, got %q", tt.want, got)
+		}
+	}
+}
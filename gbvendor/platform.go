@@ -0,0 +1,93 @@
+package vendor
+
+import (
+	"go/build"
+	"sort"
+	"strings"
+)
+
+// Platforms are the GOOS/GOARCH pairs, as in "linux/arm64", whose build
+// constraints are evaluated when parsing imports and loading packages. A
+// file is used if it matches any of them, so the imports are the union of
+// those of each platform. If empty, only the host platform is considered.
+var Platforms []string
+
+// buildContexts returns the build contexts of Platforms, or build.Default.
+func buildContexts() []*build.Context {
+	if len(Platforms) == 0 {
+		return []*build.Context{&build.Default}
+	}
+	var ctxts []*build.Context
+	for _, p := range Platforms {
+		ctxt := build.Default
+		ctxt.GOOS, ctxt.GOARCH = splitPlatform(p)
+		ctxts = append(ctxts, &ctxt)
+	}
+	return ctxts
+}
+
+func splitPlatform(p string) (goos, goarch string) {
+	i := strings.Index(p, "/")
+	return p[:i], p[i+1:]
+}
+
+// matchFile reports whether the file name in dir matches the build
+// constraints of any of Platforms.
+func matchFile(dir, name string) (bool, error) {
+	for _, ctxt := range buildContexts() {
+		ok, err := ctxt.MatchFile(dir, name)
+		if ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// importDir is like build.ImportDir, but the imports are the union of those
+// for each of Platforms. It only fails with *build.NoGoError if no platform
+// has a Go file in dir.
+func importDir(dir string, mode build.ImportMode) (*build.Package, error) {
+	var pkg, empty *build.Package
+	var noGo error
+	for _, ctxt := range buildContexts() {
+		p, err := ctxt.ImportDir(dir, mode)
+		switch err.(type) {
+		case nil:
+		case *build.NoGoError:
+			if noGo == nil {
+				empty, noGo = p, err
+			}
+			continue
+		default:
+			return p, err
+		}
+		if pkg == nil {
+			pkg = p
+			continue
+		}
+		pkg.Imports = mergeImports(pkg.Imports, p.Imports)
+		pkg.TestImports = mergeImports(pkg.TestImports, p.TestImports)
+		pkg.XTestImports = mergeImports(pkg.XTestImports, p.XTestImports)
+	}
+	if pkg == nil {
+		return empty, noGo
+	}
+	return pkg, nil
+}
+
+// mergeImports returns the sorted union of the import paths a and b.
+func mergeImports(a, b []string) []string {
+	set := make(map[string]bool)
+	for _, s := range a {
+		set[s] = true
+	}
+	for _, s := range b {
+		set[s] = true
+	}
+	u := make([]string, 0, len(set))
+	for s := range set {
+		u = append(u, s)
+	}
+	sort.Strings(u)
+	return u
+}
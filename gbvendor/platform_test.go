@@ -0,0 +1,57 @@
+package vendor
+
+import (
+	"go/build"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPlatforms(t *testing.T) {
+	root := mktemp(t)
+	defer RemoveAll(root)
+	dir := filepath.Join(root, "pkg")
+	if err := mkdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range map[string]string{
+		"pkg.go":         "package pkg\n",
+		"pkg_linux.go":   "package pkg\nimport _ \"github.com/linux/only\"\n",
+		"pkg_windows.go": "package pkg\nimport _ \"github.com/windows/only\"\n",
+		"pkg_darwin.go":  "//go:build darwin && arm64\n\npackage pkg\nimport _ \"github.com/darwin/arm64\"\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(p []string) { Platforms = p }(Platforms)
+
+	tests := []struct {
+		platforms []string
+		want      []string
+	}{
+		{[]string{"linux/amd64"}, []string{"github.com/linux/only"}},
+		{[]string{"linux/amd64", "windows/386"}, []string{"github.com/linux/only", "github.com/windows/only"}},
+		{[]string{"darwin/amd64"}, nil},
+		{[]string{"darwin/arm64", "plan9/amd64"}, []string{"github.com/darwin/arm64"}},
+	}
+	for _, tt := range tests {
+		Platforms = tt.platforms
+		got, err := ParseImports(root, false)
+		if err != nil {
+			t.Fatalf("ParseImports for %v: %v", tt.platforms, err)
+		}
+		if want := set(tt.want...); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseImports for %v: want %v, got %v", tt.platforms, want, got)
+		}
+
+		p, err := importDir(dir, build.ImportComment)
+		if err != nil {
+			t.Fatalf("importDir for %v: %v", tt.platforms, err)
+		}
+		if len(p.Imports) != len(tt.want) || (len(tt.want) > 0 && !reflect.DeepEqual(p.Imports, tt.want)) {
+			t.Errorf("importDir for %v: want imports %v, got %v", tt.platforms, tt.want, p.Imports)
+		}
+	}
+}
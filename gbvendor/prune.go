@@ -0,0 +1,114 @@
+package vendor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PruneFiles removes the files which are not needed to build the packages
+// in dir: test files, testdata directories, CI configuration and markdown
+// documentation. License, copying and notice files are always kept.
+func PruneFiles(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if isLegalFile(info.Name()) {
+			return nil
+		}
+		if info.IsDir() {
+			if info.Name() == "testdata" {
+				if err := RemoveAll(path); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isPrunable(info.Name()) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// PrunePackages removes from dir, holding the packages of import path
+// importpath, the packages not in keep, along with the files and
+// directories without Go files below each. License, copying and notice
+// files are always kept, and so are the directories in skip and nested
+// vendor directories.
+func PrunePackages(dir, importpath string, keep map[string]bool, skip ...string) error {
+	skipped := make(map[string]bool)
+	for _, s := range skip {
+		skipped[s] = true
+	}
+	return prunePackages(dir, dir, importpath, false, keep, skipped)
+}
+
+// prunePackages prunes dir, of import path importpath, whose closest
+// enclosing package was kept if inherited is set.
+func prunePackages(root, dir, importpath string, inherited bool, keep, skip map[string]bool) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	kept := inherited
+	for _, fi := range files {
+		if !fi.IsDir() && filepath.Ext(fi.Name()) == ".go" {
+			kept = keep[importpath]
+			break
+		}
+	}
+	left := len(files)
+	for _, fi := range files {
+		path := filepath.Join(dir, fi.Name())
+		switch {
+		case fi.IsDir() && (skip[path] || fi.Name() == "vendor"):
+		case fi.IsDir():
+			if err := prunePackages(root, path, importpath+"/"+fi.Name(), kept, keep, skip); err != nil {
+				return err
+			}
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				left--
+			}
+		case !kept && !isLegalFile(fi.Name()):
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			left--
+		}
+	}
+	if left == 0 && dir != root {
+		return os.Remove(dir)
+	}
+	return nil
+}
+
+func isPrunable(name string) bool {
+	switch {
+	case strings.HasSuffix(name, "_test.go"):
+		return true
+	case strings.EqualFold(filepath.Ext(name), ".md"):
+		return true
+	case name == ".travis.yml", name == "appveyor.yml", name == ".appveyor.yml":
+		return true
+	}
+	return false
+}
+
+// isLegalFile reports whether name looks like a license, copying or
+// notice file, such as LICENSE, LICENSE.md or COPYING.txt.
+func isLegalFile(name string) bool {
+	name = strings.ToUpper(name)
+	for _, prefix := range []string{"LICENSE", "LICENCE", "COPYING", "NOTICE"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
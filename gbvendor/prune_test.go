@@ -0,0 +1,89 @@
+package vendor
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneFiles(t *testing.T) {
+	dir := mktemp(t)
+	defer RemoveAll(dir)
+
+	keep := []string{
+		"foo.go",
+		"LICENSE",
+		"LICENSE.md",
+		"NOTICE",
+		"sub/COPYING.txt",
+		"sub/bar.go",
+		"sub/bar.go.txt",
+	}
+	remove := []string{
+		"foo_test.go",
+		"README.md",
+		".travis.yml",
+		"sub/CHANGES.MD",
+		"sub/bar_test.go",
+		"testdata/data.go",
+		"sub/testdata/LICENSE",
+	}
+	for _, f := range append(keep, remove...) {
+		writeFile(t, filepath.Join(dir, f), "")
+	}
+
+	if err := PruneFiles(dir); err != nil {
+		t.Fatalf("PruneFiles: %v", err)
+	}
+	for _, f := range keep {
+		assertExists(t, filepath.Join(dir, f))
+	}
+	for _, f := range remove {
+		assertNotExists(t, filepath.Join(dir, f))
+	}
+	assertNotExists(t, filepath.Join(dir, "testdata"))
+}
+
+func TestPrunePackages(t *testing.T) {
+	dir := mktemp(t)
+	defer RemoveAll(dir)
+
+	keep := []string{
+		"LICENSE",
+		"go/packages/packages.go",
+		"go/packages/testdata/a.txt",
+		"go/packages/assets/logo.png",
+		"internal/gocommand/invoke.go",
+		"cmd/LICENSE",
+		"nested/dep.go",
+		"vendor/example.com/x/x.go",
+	}
+	remove := []string{
+		"README.md",
+		"tools.go",
+		"go/doc.txt",
+		"go/ast/astutil/util.go",
+		"go/ast/astutil/testdata/b.txt",
+		"cmd/godoc/main.go",
+		"internal/unused/unused.go",
+	}
+	for _, f := range append(keep, remove...) {
+		writeFile(t, filepath.Join(dir, filepath.FromSlash(f)), "")
+	}
+
+	kept := map[string]bool{
+		"golang.org/x/tools/go/packages":        true,
+		"golang.org/x/tools/internal/gocommand": true,
+	}
+	if err := PrunePackages(dir, "golang.org/x/tools", kept, filepath.Join(dir, "nested")); err != nil {
+		t.Fatalf("PrunePackages: %v", err)
+	}
+	for _, f := range keep {
+		assertExists(t, filepath.Join(dir, filepath.FromSlash(f)))
+	}
+	for _, f := range remove {
+		assertNotExists(t, filepath.Join(dir, filepath.FromSlash(f)))
+	}
+	assertNotExists(t, filepath.Join(dir, "go", "ast"))
+	assertNotExists(t, filepath.Join(dir, "internal", "unused"))
+	assertNotExists(t, filepath.Join(dir, "cmd", "godoc"))
+}
@@ -2,16 +2,19 @@ package vendor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // RemoteRepo describes a remote dvcs repository.
@@ -27,6 +30,17 @@ type RemoteRepo interface {
 	URL() string
 }
 
+// CheckoutContext is like repo.Checkout, but the VCS commands are killed if
+// ctx is done before they complete.
+func CheckoutContext(ctx context.Context, repo RemoteRepo, branch, tag, revision string) (WorkingCopy, error) {
+	if r, ok := repo.(interface {
+		checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error)
+	}); ok {
+		return r.checkout(ctx, branch, tag, revision)
+	}
+	return repo.Checkout(branch, tag, revision)
+}
+
 // WorkingCopy represents a local copy of a remote dvcs repository.
 type WorkingCopy interface {
 
@@ -56,7 +70,29 @@ var (
 // Remote repositories can be bare import paths, or urls including a checkout scheme.
 // If deduction would cause traversal of an insecure host, a message will be
 // printed and the travelsal path will be ignored.
+// Repositories are cached in CacheDir, if set.
+// If Offline is set, it always fails, as the repository couldn't be fetched.
 func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
+	return DeduceRemoteRepoContext(context.Background(), path, insecure)
+}
+
+// DeduceRemoteRepoContext is like DeduceRemoteRepo, but the VCS commands
+// probing the repository are killed if ctx is done before they complete.
+func DeduceRemoteRepoContext(ctx context.Context, path string, insecure bool) (RemoteRepo, string, error) {
+	if Offline {
+		return nil, "", fmt.Errorf("fetching %s needs the network, but running offline", path)
+	}
+	if repo, extra, ok := lookupRepo(path, insecure); ok {
+		return repo, extra, nil
+	}
+	repo, extra, err := deduceRemoteRepo(ctx, path, insecure)
+	if err == nil && strings.HasSuffix(path, extra) {
+		storeRepo(path[:len(path)-len(extra)], repo)
+	}
+	return repo, extra, err
+}
+
+func deduceRemoteRepo(ctx context.Context, path string, insecure bool) (RemoteRepo, string, error) {
 	u, err := url.Parse(path)
 	if err != nil {
 		return nil, "", fmt.Errorf("%q is not a valid import path", path)
@@ -79,7 +115,7 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 			Host: "github.com",
 			Path: v[2],
 		}
-		repo, err := Gitrepo(url, insecure, schemes...)
+		repo, err := newGitrepo(ctx, url, insecure, schemes...)
 		return repo, v[0][len(v[1]):], err
 	case bbregex.MatchString(path):
 		v := bbregex.FindStringSubmatch(path)
@@ -87,40 +123,37 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 			Host: "bitbucket.org",
 			Path: v[2],
 		}
-		repo, err := Gitrepo(url, insecure, schemes...)
-		if err == nil {
-			return repo, v[0][len(v[1]):], nil
-		}
-		repo, err = Hgrepo(url, insecure)
-		if err == nil {
-			return repo, v[0][len(v[1]):], nil
+		repo, err := vcsRepo(ctx, path, insecure, schemes, candidate{"git", url}, candidate{"hg", url})
+		if err != nil {
+			return nil, "", err
 		}
-		return nil, "", fmt.Errorf("unknown repository type")
+		return repo, v[0][len(v[1]):], nil
 	case gcregex.MatchString(path):
 		v := gcregex.FindStringSubmatch(path)
 		url := &url.URL{
 			Host: "code.google.com",
 			Path: "p/" + v[2],
 		}
-		repo, err := Hgrepo(url, insecure, schemes...)
-		if err == nil {
-			return repo, v[0][len(v[1]):], nil
-		}
-		repo, err = Gitrepo(url, insecure, schemes...)
-		if err == nil {
-			return repo, v[0][len(v[1]):], nil
+		repo, err := vcsRepo(ctx, path, insecure, schemes, candidate{"hg", url}, candidate{"git", url})
+		if err != nil {
+			return nil, "", err
 		}
-		return nil, "", fmt.Errorf("unknown repository type")
+		return repo, v[0][len(v[1]):], nil
+	case gopkginregex.MatchString(path):
+		return gopkginrepo(ctx, path, insecure, schemes...)
 	case lpregex.MatchString(path):
 		v := lpregex.FindStringSubmatch(path)
 		v = append(v, "", "")
 		if v[2] == "" {
-			// launchpad.net/project"
-			repo, err := Bzrrepo(fmt.Sprintf("https://launchpad.net/%v", v[1]))
+			// launchpad.net/project, a bzr branch or a git repository
+			repo, err := vcsRepo(ctx, path, insecure, schemes,
+				candidate{"bzr", &url.URL{Host: "launchpad.net", Path: v[1]}},
+				candidate{"git", &url.URL{Host: "git.launchpad.net", Path: v[1]}})
 			return repo, "", err
 		}
-		// launchpad.net/project/series"
-		repo, err := Bzrrepo(fmt.Sprintf("https://launchpad.net/%s/%s", v[1], v[2]))
+		// launchpad.net/project/series, series are only bzr branches
+		repo, err := vcsRepo(ctx, path, insecure, schemes,
+			candidate{"bzr", &url.URL{Host: "launchpad.net", Path: v[1] + v[2]}})
 		return repo, v[3], err
 	}
 
@@ -134,7 +167,7 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 				Host: x[0],
 				Path: x[1],
 			}
-			repo, err := Gitrepo(url, insecure, schemes...)
+			repo, err := newGitrepo(ctx, url, insecure, schemes...)
 			return repo, v[6], err
 		case "hg":
 			x := strings.SplitN(v[1], "/", 2)
@@ -142,10 +175,18 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 				Host: x[0],
 				Path: x[1],
 			}
-			repo, err := Hgrepo(url, insecure, schemes...)
+			repo, err := newHgrepo(ctx, url, insecure, schemes...)
 			return repo, v[6], err
 		case "bzr":
-			repo, err := Bzrrepo("https://" + v[1])
+			repo, err := newBzrrepo(ctx, "https://"+v[1])
+			return repo, v[6], err
+		case "svn":
+			x := strings.SplitN(v[1], "/", 2)
+			url := &url.URL{
+				Host: x[0],
+				Path: x[1],
+			}
+			repo, err := newSvnrepo(ctx, url, insecure, schemes...)
 			return repo, v[6], err
 		default:
 			return nil, "", fmt.Errorf("unknown repository type: %q", v[5])
@@ -156,7 +197,14 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 	// no idea, try to resolve as a vanity import
 	importpath, vcs, reporoot, err := ParseMetadata(path, insecure)
 	if err != nil {
-		return nil, "", err
+		if vcsFor(path) == "" {
+			return nil, "", err
+		}
+		// without metadata the import path is the repository root
+		Debugf("could not find the go-import metadata of %s: %v", path, err)
+		x := strings.SplitN(path, "/", 2)
+		repo, err := vcsRepo(ctx, path, insecure, schemes, candidate{"", &url.URL{Host: x[0], Path: x[1]}})
+		return repo, "", err
 	}
 	u, err = url.Parse(reporoot)
 	if err != nil {
@@ -165,15 +213,19 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 	extra := path[len(importpath):]
 	switch vcs {
 	case "git":
-		u.Path = u.Path[1:]
-		repo, err := Gitrepo(u, insecure, u.Scheme)
+		u.Path = strings.TrimPrefix(u.Path, "/")
+		repo, err := newGitrepo(ctx, u, insecure, u.Scheme)
 		return repo, extra, err
 	case "hg":
-		u.Path = u.Path[1:]
-		repo, err := Hgrepo(u, insecure, u.Scheme)
+		u.Path = strings.TrimPrefix(u.Path, "/")
+		repo, err := newHgrepo(ctx, u, insecure, u.Scheme)
 		return repo, extra, err
 	case "bzr":
-		repo, err := Bzrrepo(reporoot)
+		repo, err := newBzrrepo(ctx, reporoot)
+		return repo, extra, err
+	case "svn":
+		u.Path = strings.TrimPrefix(u.Path, "/")
+		repo, err := newSvnrepo(ctx, u, insecure, u.Scheme)
 		return repo, extra, err
 	default:
 		return nil, "", fmt.Errorf("unknown repository type: %q", vcs)
@@ -182,10 +234,14 @@ func DeduceRemoteRepo(path string, insecure bool) (RemoteRepo, string, error) {
 
 // Gitrepo returns a RemoteRepo representing a remote git repository.
 func Gitrepo(url *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
+	return newGitrepo(context.Background(), url, insecure, schemes...)
+}
+
+func newGitrepo(ctx context.Context, url *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
 	if len(schemes) == 0 {
 		schemes = []string{"https", "git", "ssh", "http"}
 	}
-	u, err := probeGitUrl(url, insecure, schemes)
+	u, err := probeGitUrl(ctx, url, insecure, schemes)
 	if err != nil {
 		return nil, err
 	}
@@ -194,9 +250,9 @@ func Gitrepo(url *url.URL, insecure bool, schemes ...string) (RemoteRepo, error)
 	}, nil
 }
 
-func probeGitUrl(u *url.URL, insecure bool, schemes []string) (string, error) {
+func probeGitUrl(ctx context.Context, u *url.URL, insecure bool, schemes []string) (string, error) {
 	git := func(url *url.URL) error {
-		out, err := run("git", "ls-remote", url.String(), "HEAD")
+		out, err := run(ctx, "git", "ls-remote", mirrorURL(url.String()), "HEAD")
 		if err != nil {
 			return err
 		}
@@ -209,17 +265,17 @@ func probeGitUrl(u *url.URL, insecure bool, schemes []string) (string, error) {
 	return probe(git, u, insecure, schemes...)
 }
 
-func probeHgUrl(u *url.URL, insecure bool, schemes []string) (string, error) {
+func probeHgUrl(ctx context.Context, u *url.URL, insecure bool, schemes []string) (string, error) {
 	hg := func(url *url.URL) error {
-		_, err := run("hg", "identify", url.String())
+		_, err := run(ctx, "hg", "identify", mirrorURL(url.String()))
 		return err
 	}
 	return probe(hg, u, insecure, schemes...)
 }
 
-func probeBzrUrl(u string) error {
+func probeBzrUrl(ctx context.Context, u string) error {
 	bzr := func(url *url.URL) error {
-		_, err := run("bzr", "info", url.String())
+		_, err := run(ctx, "bzr", "info", mirrorURL(url.String()))
 		return err
 	}
 	url, err := url.Parse(u)
@@ -230,6 +286,22 @@ func probeBzrUrl(u string) error {
 	return err
 }
 
+// InsecureHosts holds the hosts which may be accessed with insecure
+// protocols even when insecure is false. A host may include a port.
+var InsecureHosts = make(map[string]bool)
+
+// isInsecureHost reports whether insecure protocols are allowed for host,
+// either because of insecure or because host is one of InsecureHosts.
+func isInsecureHost(host string, insecure bool) bool {
+	if insecure || InsecureHosts[host] {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return InsecureHosts[h]
+	}
+	return false
+}
+
 // probe calls the supplied vcs function to probe a variety of url constructions.
 // If vcs returns non nil, it is assumed that the url is not a valid repo.
 func probe(vcs func(*url.URL) error, url *url.URL, insecure bool, schemes ...string) (string, error) {
@@ -245,8 +317,8 @@ func probe(vcs func(*url.URL) error, url *url.URL, insecure bool, schemes ...str
 			if err := vcs(&url); err == nil {
 				return url.String(), nil
 			}
-		case "http", "git":
-			if !insecure {
+		case "http", "git", "svn":
+			if !isInsecureHost(url.Host, insecure) {
 				log.Printf("skipping insecure protocol: %s", url.String())
 				continue
 			}
@@ -277,6 +349,10 @@ func (g *gitrepo) URL() string {
 // then the default remote branch will be used. If the branch is "HEAD", an
 // error will be returned.
 func (g *gitrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+	return g.checkout(context.Background(), branch, tag, revision)
+}
+
+func (g *gitrepo) checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error) {
 	if branch == "HEAD" {
 		return nil, fmt.Errorf("cannot update %q as it has been previously fetched with -tag or -revision. Please use gvt delete then fetch again.", g.url)
 	}
@@ -294,26 +370,34 @@ func (g *gitrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 	args := []string{
 		"clone",
 		"-q", // silence progress report to stderr
-		g.url,
+		mirrorURL(g.url),
 		dir,
 	}
 	if branch != "" {
 		args = append(args, "--branch", branch)
 	}
+	// a revision might not be the tip of any branch or tag
+	shallow := shallowClone(ctx) && revision == ""
+	if shallow {
+		args = append(args, "--depth", "1")
+		if tag != "" {
+			args = append(args, "--branch", tag)
+		}
+	}
 
-	if _, err := run("git", args...); err != nil {
+	if _, err := run(ctx, "git", args...); err != nil {
 		wc.Destroy()
 		return nil, err
 	}
 
-	if revision != "" || tag != "" {
-		if err := runOutPath(os.Stderr, dir, "git", "checkout", "-q", oneOf(revision, tag)); err != nil {
+	if revision != "" || (tag != "" && !shallow) {
+		if err := runOutPath(ctx, os.Stderr, dir, "git", "checkout", "-q", oneOf(revision, tag)); err != nil {
 			wc.Destroy()
 			return nil, err
 		}
 	}
 
-	return &GitClone{wc}, nil
+	return &GitClone{workingcopy: wc, shallow: shallow}, nil
 }
 
 type workingcopy struct {
@@ -330,27 +414,76 @@ func (w workingcopy) Destroy() error {
 	return cleanPath(parent)
 }
 
+// ShallowClone makes git checkouts of a branch or tag fetch only its last
+// commit. Checkouts of a revision always fetch the whole history.
+var ShallowClone bool
+
+type shallowKey struct{}
+
+// WithShallowClone returns a copy of ctx which makes the git checkouts
+// done with CheckoutContext shallow if shallow is true, or not, instead of
+// following ShallowClone.
+func WithShallowClone(ctx context.Context, shallow bool) context.Context {
+	return context.WithValue(ctx, shallowKey{}, shallow)
+}
+
+// shallowClone reports whether git checkouts done with ctx are shallow.
+func shallowClone(ctx context.Context) bool {
+	if shallow, ok := ctx.Value(shallowKey{}).(bool); ok {
+		return shallow
+	}
+	return ShallowClone
+}
+
+// Offline makes DeduceRemoteRepo fail instead of accessing the network, so
+// that nothing is fetched.
+var Offline bool
+
 // GitClone is a git WorkingCopy.
 type GitClone struct {
 	workingcopy
+	shallow bool
 }
 
+// Shallow reports whether the working copy only has the last commit.
+func (g *GitClone) Shallow() bool { return g.shallow }
+
 func (g *GitClone) Revision() (string, error) {
-	rev, err := runPath(g.path, "git", "rev-parse", "HEAD")
+	rev, err := runPath(context.Background(), g.path, "git", "rev-parse", "HEAD")
 	return strings.TrimSpace(string(rev)), err
 }
 
 func (g *GitClone) Branch() (string, error) {
-	rev, err := runPath(g.path, "git", "rev-parse", "--abbrev-ref", "HEAD")
+	rev, err := runPath(context.Background(), g.path, "git", "rev-parse", "--abbrev-ref", "HEAD")
 	return strings.TrimSpace(string(rev)), err
 }
 
+// Remote returns the URL of the origin remote of the working copy.
+func (g *GitClone) Remote() (string, error) {
+	url, err := runPath(context.Background(), g.path, "git", "config", "--get", "remote.origin.url")
+	return strings.TrimSpace(string(url)), err
+}
+
+// LocalGitClone returns the git working copy rooted at dir, if dir holds
+// its git metadata. Destroy removes dir, so it should not be called on
+// working copies which are not temporary.
+func LocalGitClone(dir string) (*GitClone, bool) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		return nil, false
+	}
+	return &GitClone{workingcopy: workingcopy{dir}}, true
+}
+
 // Hgrepo returns a RemoteRepo representing a remote git repository.
 func Hgrepo(u *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
+	return newHgrepo(context.Background(), u, insecure, schemes...)
+}
+
+func newHgrepo(ctx context.Context, u *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
 	if len(schemes) == 0 {
 		schemes = []string{"https", "http"}
 	}
-	url, err := probeHgUrl(u, insecure, schemes)
+	url, err := probeHgUrl(ctx, u, insecure, schemes)
 	if err != nil {
 		return nil, err
 	}
@@ -369,6 +502,10 @@ type hgrepo struct {
 func (h *hgrepo) URL() string { return h.url }
 
 func (h *hgrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+	return h.checkout(context.Background(), branch, tag, revision)
+}
+
+func (h *hgrepo) checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error) {
 	if !atMostOne(tag, revision) {
 		return nil, fmt.Errorf("only one of tag or revision may be supplied")
 	}
@@ -378,19 +515,19 @@ func (h *hgrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 	}
 	args := []string{
 		"clone",
-		h.url,
+		mirrorURL(h.url),
 		dir,
 	}
 
 	if branch != "" {
 		args = append(args, "--branch", branch)
 	}
-	if err := runOut(os.Stderr, "hg", args...); err != nil {
+	if err := runOut(ctx, os.Stderr, "hg", args...); err != nil {
 		RemoveAll(dir)
 		return nil, err
 	}
 	if revision != "" {
-		if err := runOut(os.Stderr, "hg", "--cwd", dir, "update", "-r", revision); err != nil {
+		if err := runOut(ctx, os.Stderr, "hg", "--cwd", dir, "update", "-r", revision); err != nil {
 			RemoveAll(dir)
 			return nil, err
 		}
@@ -409,18 +546,22 @@ type HgClone struct {
 }
 
 func (h *HgClone) Revision() (string, error) {
-	rev, err := run("hg", "--cwd", h.path, "id", "-i")
+	rev, err := run(context.Background(), "hg", "--cwd", h.path, "id", "-i")
 	return strings.TrimSpace(string(rev)), err
 }
 
 func (h *HgClone) Branch() (string, error) {
-	rev, err := run("hg", "--cwd", h.path, "branch")
+	rev, err := run(context.Background(), "hg", "--cwd", h.path, "branch")
 	return strings.TrimSpace(string(rev)), err
 }
 
 // Bzrrepo returns a RemoteRepo representing a remote bzr repository.
 func Bzrrepo(url string) (RemoteRepo, error) {
-	if err := probeBzrUrl(url); err != nil {
+	return newBzrrepo(context.Background(), url)
+}
+
+func newBzrrepo(ctx context.Context, url string) (RemoteRepo, error) {
+	if err := probeBzrUrl(ctx, url); err != nil {
 		return nil, err
 	}
 	return &bzrrepo{
@@ -440,6 +581,10 @@ func (b *bzrrepo) URL() string {
 }
 
 func (b *bzrrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+	return b.checkout(context.Background(), branch, tag, revision)
+}
+
+func (b *bzrrepo) checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error) {
 	if !atMostOne(tag, revision) {
 		return nil, fmt.Errorf("only one of tag or revision may be supplied")
 	}
@@ -448,7 +593,7 @@ func (b *bzrrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
 		return nil, err
 	}
 	wc := filepath.Join(dir, "wc")
-	if err := runOut(os.Stderr, "bzr", "branch", b.url, wc); err != nil {
+	if err := runOut(ctx, os.Stderr, "bzr", "branch", mirrorURL(b.url), wc); err != nil {
 		RemoveAll(dir)
 		return nil, err
 	}
@@ -492,31 +637,88 @@ func mktmp() (string, error) {
 	return ioutil.TempDir("", "gvt-")
 }
 
-func run(c string, args ...string) ([]byte, error) {
+func run(ctx context.Context, c string, args ...string) ([]byte, error) {
 	var buf bytes.Buffer
-	err := runOut(&buf, c, args...)
+	err := runOut(ctx, &buf, c, args...)
 	return buf.Bytes(), err
 }
 
-func runOut(w io.Writer, c string, args ...string) error {
-	cmd := exec.Command(c, args...)
+func runOut(ctx context.Context, w io.Writer, c string, args ...string) error {
+	cmd := exec.CommandContext(ctx, c, args...)
 	cmd.Stdout = w
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return runCmd(cmd)
 }
 
-func runPath(path string, c string, args ...string) ([]byte, error) {
+func runPath(ctx context.Context, path string, c string, args ...string) ([]byte, error) {
 	var buf bytes.Buffer
-	err := runOutPath(&buf, path, c, args...)
+	err := runOutPath(ctx, &buf, path, c, args...)
 	return buf.Bytes(), err
 }
 
-func runOutPath(w io.Writer, path string, c string, args ...string) error {
-	cmd := exec.Command(c, args...)
+func runOutPath(ctx context.Context, w io.Writer, path string, c string, args ...string) error {
+	cmd := exec.CommandContext(ctx, c, args...)
 	cmd.Dir = path
 	cmd.Stdout = w
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return runCmd(cmd)
+}
+
+// runCmd runs cmd, copying its standard error to os.Stderr. If cmd fails
+// the error is returned as a *cmdError carrying that output. If cmd was
+// created with a context which is done, it is killed, and helpers it left
+// behind holding its output are not waited for more than waitDelay.
+func runCmd(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	cmd.Env = vcsEnv(cmd.Args)
+	cmd.WaitDelay = waitDelay
+	if err := cmd.Run(); err != nil {
+		return &cmdError{err: err, stderr: stderr.String()}
+	}
+	return nil
+}
+
+const waitDelay = time.Second
+
+// cmdError is the error of a failed vcs command.
+type cmdError struct {
+	err    error
+	stderr string
+}
+
+func (e *cmdError) Error() string { return e.err.Error() }
+
+// temporaryErrors are fragments of vcs error output caused by network
+// conditions that may go away if the command is retried.
+var temporaryErrors = []string{
+	"Could not resolve host",
+	"Temporary failure in name resolution",
+	"Connection timed out",
+	"Connection refused",
+	"Connection reset",
+	"Operation timed out",
+	"Network is unreachable",
+	"The remote end hung up unexpectedly",
+	"early EOF",
+	"RPC failed",
+	"SSL_ERROR",
+	"gnutls_handshake",
+	"timed out",
+}
+
+// IsTemporary reports whether err is a failure of a vcs command caused by
+// a network or transport problem, and so worth retrying. Errors like a
+// missing repository or revision are not temporary.
+func IsTemporary(err error) bool {
+	e, ok := err.(*cmdError)
+	if !ok {
+		return false
+	}
+	for _, s := range temporaryErrors {
+		if strings.Contains(e.stderr, s) {
+			return true
+		}
+	}
+	return false
 }
 
 // atMostOne returns true if no more than one string supplied is not empty.
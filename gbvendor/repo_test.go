@@ -1,9 +1,18 @@
 package vendor
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDeduceRemoteRepo(t *testing.T) {
@@ -79,8 +88,11 @@ func TestDeduceRemoteRepo(t *testing.T) {
 		extra: "/lib/go/thrift",
 	}, {
 		path: "gopkg.in/check.v1",
-		want: &gitrepo{
-			url: "https://gopkg.in/check.v1",
+		want: &gopkginRepo{
+			gitrepo: &gitrepo{
+				url: "https://github.com/go-check/check",
+			},
+			major: "v1",
 		},
 		extra: "",
 	}, {
@@ -89,6 +101,18 @@ func TestDeduceRemoteRepo(t *testing.T) {
 			url: "https://go.googlesource.com/tools",
 		},
 		extra: "/go/vcs",
+	}, {
+		path: "golang.org/x/tools/go/packages",
+		want: &gitrepo{
+			url: "https://go.googlesource.com/tools",
+		},
+		extra: "/go/packages",
+	}, {
+		path: "golang.org/x/net/http2/hpack",
+		want: &gitrepo{
+			url: "https://go.googlesource.com/net",
+		},
+		extra: "/http2/hpack",
 	}, {
 		path: "labix.org/v2/mgo",
 		want: &bzrrepo{
@@ -137,3 +161,223 @@ func TestDeduceRemoteRepo(t *testing.T) {
 		}
 	}
 }
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{{
+		err:  fmt.Errorf("unknown repository type"),
+		want: false,
+	}, {
+		err:  &cmdError{err: fmt.Errorf("exit status 128"), stderr: "fatal: unable to access 'https://github.com/pkg/sftp/': Could not resolve host: github.com\n"},
+		want: true,
+	}, {
+		err:  &cmdError{err: fmt.Errorf("exit status 128"), stderr: "error: RPC failed; curl 56 GnuTLS recv error (-9)\nfatal: early EOF\n"},
+		want: true,
+	}, {
+		err:  &cmdError{err: fmt.Errorf("exit status 128"), stderr: "remote: Repository not found.\nfatal: repository 'https://github.com/pkg/nope/' not found\n"},
+		want: false,
+	}}
+	for _, tt := range tests {
+		if got := IsTemporary(tt.err); got != tt.want {
+			t.Errorf("IsTemporary(%v): want %v, got %v", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestIsInsecureHost(t *testing.T) {
+	defer func(h map[string]bool) { InsecureHosts = h }(InsecureHosts)
+	InsecureHosts = map[string]bool{"internal.example.com": true, "other.example.com:8080": true}
+
+	tests := []struct {
+		host     string
+		insecure bool
+		want     bool
+	}{
+		{"github.com", false, false},
+		{"github.com", true, true},
+		{"internal.example.com", false, true},
+		{"internal.example.com:8080", false, true},
+		{"other.example.com:8080", false, true},
+		{"other.example.com", false, false},
+		{"example.com", false, false},
+	}
+	for _, tt := range tests {
+		if got := isInsecureHost(tt.host, tt.insecure); got != tt.want {
+			t.Errorf("isInsecureHost(%q, %v): want %v, got %v", tt.host, tt.insecure, tt.want, got)
+		}
+	}
+}
+
+func TestRunKilled(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skipf("sleep not found: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := run(ctx, "sleep", "10"); err == nil {
+		t.Fatal("expected the command to be killed")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("the command was not killed, it ran for %v", d)
+	}
+}
+
+func TestDeduceRemoteRepoKilled(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skipf("sh not found: %v", err)
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skipf("sleep not found: %v", err)
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "git"), []byte("#!"+sh+"\nexec "+sleep+" 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = ""
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := DeduceRemoteRepoContext(ctx, "github.com/foo/bar", false); err == nil {
+		t.Fatal("expected the probe to be killed")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("the probe was not killed, it ran for %v", d)
+	}
+}
+
+func TestShallowClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skipf("git not found: %v", err)
+	}
+	src := mktemp(t)
+	defer RemoveAll(src)
+	git := func(args ...string) string {
+		args = append([]string{"-c", "user.name=gvt", "-c", "user.email=gvt@example.com"}, args...)
+		out, err := runPath(context.Background(), src, "git", args...)
+		if err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "first")
+	first := git("rev-parse", "HEAD")
+	git("commit", "-q", "--allow-empty", "-m", "second")
+
+	defer func(s bool) { ShallowClone = s }(ShallowClone)
+	ShallowClone = true
+	repo := &gitrepo{url: "file://" + filepath.ToSlash(src)}
+
+	for _, tt := range []struct {
+		revision string
+		shallow  bool
+		commits  string
+	}{
+		{"", true, "1"},
+		{first, false, "2"},
+	} {
+		wc, err := repo.Checkout("", "", tt.revision)
+		if err != nil {
+			t.Fatalf("Checkout(%q): %v", tt.revision, err)
+		}
+		out, err := runPath(context.Background(), wc.Dir(), "git", "rev-list", "--count", "--all")
+		wc.Destroy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := wc.(*GitClone).Shallow(); got != tt.shallow {
+			t.Errorf("Checkout(%q): want shallow %v, got %v", tt.revision, tt.shallow, got)
+		}
+		if got := strings.TrimSpace(string(out)); got != tt.commits {
+			t.Errorf("Checkout(%q): want %s commits, got %s", tt.revision, tt.commits, got)
+		}
+	}
+
+	// the setting of a single checkout wins over ShallowClone
+	ctx := WithShallowClone(context.Background(), false)
+	wc, err := CheckoutContext(ctx, repo, "", "", "")
+	if err != nil {
+		t.Fatalf("CheckoutContext: %v", err)
+	}
+	wc.Destroy()
+	if wc.(*GitClone).Shallow() {
+		t.Error("CheckoutContext: want a full clone with WithShallowClone false")
+	}
+}
+
+func TestDeduceRemoteRepoOffline(t *testing.T) {
+	defer func(dir string) { CacheDir = dir }(CacheDir)
+	CacheDir = t.TempDir()
+	defer ClearCache()
+	storeRepo("github.com/foo/bar", &gitrepo{url: "https://github.com/foo/bar"})
+
+	defer func(o bool) { Offline = o }(Offline)
+	Offline = true
+	for _, path := range []string{"github.com/foo/bar", "example.com/vanity"} {
+		_, _, err := DeduceRemoteRepo(path, false)
+		if err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("DeduceRemoteRepo(%q): want an error naming it, got %v", path, err)
+		}
+	}
+}
+
+func TestDeduceRemoteRepoMetadata(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skipf("sh not found: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// repository roots with and without a path
+		for _, vcs := range []string{"git", "hg", "svn"} {
+			fmt.Fprintf(w, `<meta name="go-import" content="example.invalid/%s %s https://%s.example.invalid"/>`, vcs, vcs, vcs)
+			fmt.Fprintf(w, `<meta name="go-import" content="example.invalid/%s-path %s https://%s.example.invalid/repo"/>`, vcs, vcs, vcs)
+		}
+	}))
+	defer srv.Close()
+
+	defer func(p func(*http.Request) (*url.URL, error)) { proxy = p }(proxy)
+	proxy = func(*http.Request) (*url.URL, error) { return url.Parse(srv.URL) }
+	metadataCache.Lock()
+	saved := metadataCache.imports
+	metadataCache.imports = nil
+	metadataCache.Unlock()
+	defer func() {
+		metadataCache.Lock()
+		metadataCache.imports = saved
+		metadataCache.Unlock()
+	}()
+	defer func(h map[string]bool) { InsecureHosts = h }(InsecureHosts)
+	InsecureHosts = map[string]bool{"example.invalid": true}
+
+	tests := []struct {
+		path string
+		want RemoteRepo
+	}{
+		{"example.invalid/git/pkg", &gitrepo{url: "https://git.example.invalid"}},
+		{"example.invalid/hg/pkg", &hgrepo{url: "https://hg.example.invalid"}},
+		{"example.invalid/svn/pkg", &svnrepo{url: "https://svn.example.invalid"}},
+		{"example.invalid/git-path/pkg", &gitrepo{url: "https://git.example.invalid/repo"}},
+		{"example.invalid/hg-path/pkg", &hgrepo{url: "https://hg.example.invalid/repo"}},
+		{"example.invalid/svn-path/pkg", &svnrepo{url: "https://svn.example.invalid/repo"}},
+	}
+	for _, tt := range tests {
+		stubVCS(t, sh, "git", "hg", "svn")
+		got, extra, err := deduceRemoteRepo(context.Background(), tt.path, false)
+		if err != nil {
+			t.Errorf("deduceRemoteRepo(%q): %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || extra != "/pkg" {
+			t.Errorf("deduceRemoteRepo(%q): want %#v, /pkg, got %#v, %q", tt.path, tt.want, got, extra)
+		}
+	}
+}
@@ -1,138 +1,101 @@
 package vendor
 
-// packages from the standard lib. They are excluded
-// from the package map.
-var stdlib = map[string]bool{
-	"C":                   true,
-	"archive/tar":         true,
-	"archive/zip":         true,
-	"bufio":               true,
-	"bytes":               true,
-	"compress/bzip2":      true,
-	"compress/flate":      true,
-	"compress/gzip":       true,
-	"compress/lzw":        true,
-	"compress/zlib":       true,
-	"container/heap":      true,
-	"container/list":      true,
-	"container/ring":      true,
-	"crypto":              true,
-	"crypto/aes":          true,
-	"crypto/cipher":       true,
-	"crypto/des":          true,
-	"crypto/dsa":          true,
-	"crypto/ecdsa":        true,
-	"crypto/elliptic":     true,
-	"crypto/hmac":         true,
-	"crypto/md5":          true,
-	"crypto/rand":         true,
-	"crypto/rc4":          true,
-	"crypto/rsa":          true,
-	"crypto/sha1":         true,
-	"crypto/sha256":       true,
-	"crypto/sha512":       true,
-	"crypto/subtle":       true,
-	"crypto/tls":          true,
-	"crypto/x509":         true,
-	"crypto/x509/pkix":    true,
-	"database/sql":        true,
-	"database/sql/driver": true,
-	"debug/dwarf":         true,
-	"debug/elf":           true,
-	"debug/gosym":         true,
-	"debug/macho":         true,
-	"debug/pe":            true,
-	"encoding":            true,
-	"encoding/ascii85":    true,
-	"encoding/asn1":       true,
-	"encoding/base32":     true,
-	"encoding/base64":     true,
-	"encoding/binary":     true,
-	"encoding/csv":        true,
-	"encoding/gob":        true,
-	"encoding/hex":        true,
-	"encoding/json":       true,
-	"encoding/pem":        true,
-	"encoding/xml":        true,
-	"errors":              true,
-	"expvar":              true,
-	"flag":                true,
-	"fmt":                 true,
-	"go/ast":              true,
-	"go/build":            true,
-	"go/doc":              true,
-	"go/format":           true,
-	"go/parser":           true,
-	"go/printer":          true,
-	"go/scanner":          true,
-	"go/token":            true,
-	"hash":                true,
-	"hash/adler32":        true,
-	"hash/crc32":          true,
-	"hash/crc64":          true,
-	"hash/fnv":            true,
-	"html":                true,
-	"html/template":       true,
-	"image":               true,
-	"image/color":         true,
-	"image/draw":          true,
-	"image/gif":           true,
-	"image/jpeg":          true,
-	"image/png":           true,
-	"index/suffixarray":   true,
-	"io":                  true,
-	"io/ioutil":           true,
-	"log":                 true,
-	"log/syslog":          true,
-	"math":                true,
-	"math/big":            true,
-	"math/cmplx":          true,
-	"math/rand":           true,
-	"mime":                true,
-	"mime/multipart":      true,
-	"net":                 true,
-	"net/http":            true,
-	"net/http/cgi":        true,
-	"net/http/cookiejar":  true,
-	"net/http/fcgi":       true,
-	"net/http/httptest":   true,
-	"net/http/httputil":   true,
-	"net/http/pprof":      true,
-	"net/mail":            true,
-	"net/rpc":             true,
-	"net/rpc/jsonrpc":     true,
-	"net/smtp":            true,
-	"net/textproto":       true,
-	"net/url":             true,
-	"os":                  true,
-	"os/exec":             true,
-	"os/signal":           true,
-	"os/user":             true,
-	"path":                true,
-	"path/filepath":       true,
-	"reflect":             true,
-	"regexp":              true,
-	"regexp/syntax":       true,
-	"runtime":             true,
-	"runtime/cgo":         true,
-	"runtime/debug":       true,
-	"runtime/pprof":       true,
-	"sort":                true,
-	"strconv":             true,
-	"strings":             true,
-	"sync":                true,
-	"sync/atomic":         true,
-	"syscall":             true,
-	"testing":             true,
-	"testing/iotest":      true,
-	"testing/quick":       true,
-	"text/scanner":        true,
-	"text/tabwriter":      true,
-	"text/template":       true,
-	"text/template/parse": true,
-	"time":                true,
-	"unicode":             true,
-	"unicode/utf16":       true,
-	"unicode/utf8":        true,
-	"unsafe":              true,
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// stdlibCache holds the result of stdlibPackages for each goroot, walking
+// GOROOT is the most expensive part of classifying imports.
+var stdlibCache = struct {
+	sync.Mutex
+	pkgs map[string]map[string]bool
+}{pkgs: make(map[string]map[string]bool)}
+
+// stdlibPackages returns the import paths of the packages of the standard
+// library found under goroot. The result is cached and must not be modified.
+// If goroot has no source, a warning is printed with Warnf and the set is
+// empty, so that isRemoteImport only relies on the host name heuristic.
+func stdlibPackages(goroot string) (map[string]bool, error) {
+	stdlibCache.Lock()
+	defer stdlibCache.Unlock()
+	if pkgs, ok := stdlibCache.pkgs[goroot]; ok {
+		return pkgs, nil
+	}
+	if fi, err := os.Stat(filepath.Join(goroot, "src")); goroot == "" || err != nil || !fi.IsDir() {
+		Warnf("could not find the standard library in GOROOT %q, telling remote imports by their host name only", goroot)
+		stdlibCache.pkgs[goroot] = map[string]bool{}
+		return stdlibCache.pkgs[goroot], nil
+	}
+	pkgs, err := walkStdlib(goroot)
+	if err != nil {
+		return nil, err
+	}
+	stdlibCache.pkgs[goroot] = pkgs
+	return pkgs, nil
+}
+
+func walkStdlib(goroot string) (map[string]bool, error) {
+	pkgs := make(map[string]bool)
+	src := filepath.Join(goroot, "src")
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path == src {
+			return nil
+		}
+		name := info.Name()
+		// the vendor directories of GOROOT hold copies of golang.org/x
+		// packages, which are not part of the standard library.
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+			return filepath.SkipDir
+		}
+		pkgs[filepath.ToSlash(path[len(src)+1:])] = true
+		return nil
+	})
+	return pkgs, err
+}
+
+// ProvidedImports are the prefixes of import paths provided by the
+// platform the project runs on, like the App Engine SDK, which are never
+// vendored even if they look remote.
+var ProvidedImports = []string{"appengine", "appengine_internal"}
+
+// IsProvided reports whether path is or is inside one of ProvidedImports.
+func IsProvided(path string) bool {
+	for _, p := range ProvidedImports {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRemoteImport reports whether path is the import path of a package that
+// has to be fetched, as the imports found by ParseImports.
+func IsRemoteImport(path string) (bool, error) {
+	stdlib, err := stdlibPackages(build.Default.GOROOT)
+	if err != nil {
+		return false, fmt.Errorf("could not list standard library packages: %v", err)
+	}
+	return isRemoteImport(stdlib, path), nil
+}
+
+// isRemoteImport reports whether path is the import path of a package
+// that has to be fetched, that is, neither a local import, provided nor
+// part of the standard library, and starting with a host name.
+func isRemoteImport(stdlib map[string]bool, path string) bool {
+	if build.IsLocalImport(path) || IsProvided(path) || stdlib[path] {
+		return false
+	}
+	host := path
+	if i := strings.Index(path, "/"); i >= 0 {
+		host = path[:i]
+	}
+	return strings.Contains(host, ".")
 }
@@ -0,0 +1,88 @@
+package vendor
+
+import (
+	"go/build"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsRemoteImport(t *testing.T) {
+	stdlib, err := stdlibPackages(build.Default.GOROOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"C", false},
+		{"fmt", false},
+		{"net/http", false},
+		{"context", false},
+		{"./local", false},
+		{"../local", false},
+		{"corporate/pkg", false},
+		{"github.com/pkg/sftp", true},
+		{"golang.org/x/net/http2/hpack", true},
+		{"gopkg.in/check.v1", true},
+		{"appengine", false},
+		{"appengine/datastore", false},
+		{"appengine_internal/base", false},
+		{"appenginex.com/pkg", true},
+	}
+	for _, tt := range tests {
+		if got := isRemoteImport(stdlib, tt.path); got != tt.want {
+			t.Errorf("isRemoteImport(%q): want %v, got %v", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestStdlibPackagesCached(t *testing.T) {
+	a, err := stdlibPackages(build.Default.GOROOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := stdlibPackages(build.Default.GOROOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.ValueOf(a).Pointer() != reflect.ValueOf(b).Pointer() {
+		t.Fatal("stdlibPackages: expected the second call to return the cached set")
+	}
+}
+
+func TestStdlibPackagesNoGoroot(t *testing.T) {
+	stdlib, err := stdlibPackages(filepath.Join(t.TempDir(), "nogoroot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stdlib) != 0 {
+		t.Fatalf("stdlibPackages: expected an empty set without GOROOT, got %v", stdlib)
+	}
+	for path, want := range map[string]bool{
+		"fmt":                false,
+		"net/http":           false,
+		"github.com/foo/bar": true,
+	} {
+		if got := isRemoteImport(stdlib, path); got != want {
+			t.Errorf("isRemoteImport(%q) without GOROOT: want %v, got %v", path, want, got)
+		}
+	}
+}
+
+func TestIsRemoteImportProvided(t *testing.T) {
+	defer func(p []string) { ProvidedImports = p }(ProvidedImports)
+	ProvidedImports = append(ProvidedImports, "platform.example.com/sdk")
+	stdlib := map[string]bool{}
+	for path, want := range map[string]bool{
+		"platform.example.com/sdk":       false,
+		"platform.example.com/sdk/log":   false,
+		"platform.example.com/sdkx":      true,
+		"platform.example.com/other/pkg": true,
+	} {
+		if got := isRemoteImport(stdlib, path); got != want {
+			t.Errorf("isRemoteImport(%q): want %v, got %v", path, want, got)
+		}
+	}
+}
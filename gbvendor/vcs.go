@@ -0,0 +1,169 @@
+package vendor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VCS maps import path prefixes to the version control system, one of
+// "git", "hg", "bzr" or "svn", of the repositories under them, for hosts
+// which could serve several, like bitbucket.org or launchpad.net, and for
+// import paths without go-import metadata, whose repository root is then
+// the import path itself. The empty prefix applies to all import paths.
+// When several prefixes match the longest one is used. Import paths without
+// a match are tried with each system the host could serve, in turn.
+var VCS = make(map[string]string)
+
+// vcsFor returns the version control system VCS sets for importpath, if any.
+func vcsFor(importpath string) string {
+	prefix, ok := "", false
+	for p := range VCS {
+		if (p == "" || importpath == p || strings.HasPrefix(importpath, p+"/")) && (!ok || len(p) > len(prefix)) {
+			prefix, ok = p, true
+		}
+	}
+	return VCS[prefix]
+}
+
+// candidate is a repository location with the version control system it
+// might be served by.
+type candidate struct {
+	vcs string
+	url *url.URL
+}
+
+// vcsRepo returns the repository of importpath at the first of candidates
+// which can be reached, in order, or the one of the version control system
+// set by VCS. The system used is reported if the first one failed.
+func vcsRepo(ctx context.Context, importpath string, insecure bool, schemes []string, candidates ...candidate) (RemoteRepo, error) {
+	if vcs := vcsFor(importpath); vcs != "" {
+		c := candidate{vcs, candidates[0].url}
+		for _, cc := range candidates {
+			if cc.vcs == vcs {
+				c = cc
+			}
+		}
+		candidates = []candidate{c}
+	}
+	var errs, tried []string
+	for _, c := range candidates {
+		repo, err := newRepo(ctx, c.vcs, c.url, insecure, schemes)
+		if err != nil {
+			if len(candidates) == 1 {
+				return nil, err
+			}
+			errs = append(errs, fmt.Sprintf("%s: %v", c.vcs, err))
+			tried = append(tried, c.vcs)
+			continue
+		}
+		if len(tried) > 0 {
+			log.Printf("%s is not a %s repository, using %s", importpath, strings.Join(tried, " or "), c.vcs)
+		} else {
+			Debugf("%s is a %s repository", importpath, c.vcs)
+		}
+		return repo, nil
+	}
+	return nil, fmt.Errorf("unknown repository type, tried %s", strings.Join(errs, "; "))
+}
+
+// newRepo returns the repository of the version control system vcs at u.
+func newRepo(ctx context.Context, vcs string, u *url.URL, insecure bool, schemes []string) (RemoteRepo, error) {
+	switch vcs {
+	case "git":
+		return newGitrepo(ctx, u, insecure, schemes...)
+	case "hg":
+		return newHgrepo(ctx, u, insecure, schemes...)
+	case "bzr":
+		b := *u
+		b.Scheme = "https"
+		return newBzrrepo(ctx, b.String())
+	case "svn":
+		return newSvnrepo(ctx, u, insecure, schemes...)
+	default:
+		return nil, fmt.Errorf("unknown repository type: %q", vcs)
+	}
+}
+
+// Svnrepo returns a RemoteRepo representing a remote subversion repository.
+func Svnrepo(u *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
+	return newSvnrepo(context.Background(), u, insecure, schemes...)
+}
+
+func newSvnrepo(ctx context.Context, u *url.URL, insecure bool, schemes ...string) (RemoteRepo, error) {
+	if len(schemes) == 0 {
+		schemes = []string{"https", "svn", "http"}
+	}
+	svn := func(url *url.URL) error {
+		_, err := run(ctx, "svn", "info", "--non-interactive", mirrorURL(url.String()))
+		return err
+	}
+	url, err := probe(svn, u, insecure, schemes...)
+	if err != nil {
+		return nil, err
+	}
+	return &svnrepo{
+		url: url,
+	}, nil
+}
+
+// svnrepo is a subversion RemoteRepo.
+type svnrepo struct {
+
+	// remote repository url, see svn help checkout
+	url string
+}
+
+func (s *svnrepo) URL() string { return s.url }
+
+// Checkout checks out the revision, or the latest one if blank. Subversion
+// has no branches or tags of its own, they are directories of the
+// repository, so neither may be supplied.
+func (s *svnrepo) Checkout(branch, tag, revision string) (WorkingCopy, error) {
+	return s.checkout(context.Background(), branch, tag, revision)
+}
+
+func (s *svnrepo) checkout(ctx context.Context, branch, tag, revision string) (WorkingCopy, error) {
+	if branch != "" || tag != "" {
+		return nil, fmt.Errorf("subversion repositories have no branches or tags, fetch their directory instead")
+	}
+	dir, err := mktmp()
+	if err != nil {
+		return nil, err
+	}
+	wc := filepath.Join(dir, "wc")
+	args := []string{"checkout", "--non-interactive", "-q"}
+	if revision != "" {
+		args = append(args, "-r", revision)
+	}
+	args = append(args, mirrorURL(s.url), wc)
+	if err := runOut(ctx, os.Stderr, "svn", args...); err != nil {
+		RemoveAll(dir)
+		return nil, err
+	}
+
+	return &SvnClone{
+		workingcopy{
+			path: wc,
+		},
+	}, nil
+}
+
+// SvnClone is a subversion WorkingCopy.
+type SvnClone struct {
+	workingcopy
+}
+
+func (s *SvnClone) Revision() (string, error) {
+	rev, err := run(context.Background(), "svn", "info", "--show-item", "revision", s.path)
+	return strings.TrimSpace(string(rev)), err
+}
+
+// Branch returns "", subversion has no branches.
+func (s *SvnClone) Branch() (string, error) {
+	return "", nil
+}
@@ -0,0 +1,119 @@
+package vendor
+
+import (
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// stubVCS replaces PATH with a stub for each of the version control
+// systems, run by the shell sh, which succeeds, as if every URL was a
+// repository, only for those in ok.
+func stubVCS(t *testing.T, sh string, ok ...string) {
+	dir := t.TempDir()
+	for _, vcs := range []string{"git", "hg", "bzr", "svn"} {
+		script := "#!" + sh + "\nexit 1\n"
+		for _, o := range ok {
+			if o == vcs {
+				script = "#!" + sh + "\necho 0123456789abcdef HEAD\n"
+			}
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, vcs), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestVCSSelection(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skipf("sh not found: %v", err)
+	}
+	tests := []struct {
+		path  string
+		ok    []string
+		vcs   map[string]string
+		want  RemoteRepo
+		extra string
+	}{{
+		path:  "bitbucket.org/foo/bar/baz",
+		ok:    []string{"git", "hg"},
+		want:  &gitrepo{url: "https://bitbucket.org/foo/bar"},
+		extra: "/baz",
+	}, {
+		path:  "bitbucket.org/foo/bar/baz",
+		ok:    []string{"hg"},
+		want:  &hgrepo{url: "https://bitbucket.org/foo/bar"},
+		extra: "/baz",
+	}, {
+		path:  "bitbucket.org/foo/bar/baz",
+		ok:    []string{"git", "hg"},
+		vcs:   map[string]string{"": "git", "bitbucket.org/foo": "hg"},
+		want:  &hgrepo{url: "https://bitbucket.org/foo/bar"},
+		extra: "/baz",
+	}, {
+		path: "bitbucket.org/foo/bar",
+		ok:   []string{"git", "hg"},
+		vcs:  map[string]string{"bitbucket.org/fo": "hg"},
+		want: &gitrepo{url: "https://bitbucket.org/foo/bar"},
+	}, {
+		path: "code.google.com/p/foo",
+		ok:   []string{"svn"},
+	}, {
+		path: "launchpad.net/foo",
+		ok:   []string{"bzr", "git"},
+		want: &bzrrepo{url: "https://launchpad.net/foo"},
+	}, {
+		path: "launchpad.net/foo",
+		ok:   []string{"git"},
+		want: &gitrepo{url: "https://git.launchpad.net/foo"},
+	}, {
+		path: "launchpad.net/foo",
+		ok:   []string{"bzr", "git"},
+		vcs:  map[string]string{"": "git"},
+		want: &gitrepo{url: "https://git.launchpad.net/foo"},
+	}, {
+		path:  "launchpad.net/foo/trunk/pkg",
+		ok:    []string{"bzr", "git"},
+		want:  &bzrrepo{url: "https://launchpad.net/foo/trunk"},
+		extra: "/pkg",
+	}, {
+		path: "launchpad.net/foo/trunk/pkg",
+		ok:   []string{"git"},
+	}, {
+		path:  "example.com/repo.svn/pkg",
+		ok:    []string{"svn"},
+		want:  &svnrepo{url: "https://example.com/repo.svn"},
+		extra: "/pkg",
+	}}
+	defer func(v map[string]string) { VCS = v }(VCS)
+	for _, tt := range tests {
+		stubVCS(t, sh, tt.ok...)
+		VCS = tt.vcs
+		got, extra, err := deduceRemoteRepo(context.Background(), tt.path, false)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("deduceRemoteRepo(%q) with %v available: want an error, got %#v", tt.path, tt.ok, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("deduceRemoteRepo(%q) with %v available: %v", tt.path, tt.ok, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || extra != tt.extra {
+			t.Errorf("deduceRemoteRepo(%q) with %v available: want %#v, %q, got %#v, %q", tt.path, tt.ok, tt.want, tt.extra, got, extra)
+		}
+	}
+}
+
+func TestSvnCheckoutBranch(t *testing.T) {
+	repo := &svnrepo{url: "https://example.com/repo"}
+	if _, err := repo.Checkout("trunk", "", ""); err == nil {
+		t.Fatal("Checkout: expected an error checking out a subversion branch")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	graphPkg   string // only print what is reachable from this package
+	graphTests bool   // include the imports of the project tests
+)
+
+func addGraphFlags(fs *flag.FlagSet) {
+	fs.StringVar(&graphPkg, "pkg", "", "only print the dependencies reachable from this package")
+	fs.BoolVar(&graphTests, "tests", false, "include the imports of the project tests")
+	fs.StringVar(&self, "self", "", "import path of the project")
+	addParseFlags(fs)
+}
+
+var cmdGraph = &Command{
+	Name:      "graph",
+	UsageLine: "graph [-pkg importpath] [-tests] [-self importpath] [-follow-symlinks] [-skip-errors] [-provided prefix] [-exclude-dir pattern] [-platforms list]",
+	Short:     "print the dependency graph in DOT format",
+	Long: `graph prints the graph of the dependencies in the manifest in the Graphviz DOT
+format, ready to be piped into a command like "dot -Tsvg".
+
+Each node is the project or a dependency, and there is an edge from a node
+to each dependency one of its packages imports. Dependencies nothing imports
+are printed as nodes without edges.
+
+Flags:
+	-pkg importpath
+		only print the part of the graph reachable from the dependency, or
+		the project, containing the package importpath.
+	-tests
+		include the imports of the tests of the project.
+	-self importpath
+		the import path of the project, used as the name of its node. If not
+		supplied it is deduced from the location of the project in GOPATH.
+
+The parsing flags, like -provided and -platforms, are also accepted, see
+"gvt help parsing".
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("graph takes no arguments")
+		}
+		return graph()
+	},
+	AddFlags: addGraphFlags,
+}
+
+func graph() error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+	root, edges, err := dependencyGraph(m, graphTests)
+	if err != nil {
+		return err
+	}
+
+	nodes := []string{root}
+	for _, d := range m.Dependencies {
+		nodes = append(nodes, d.Importpath)
+	}
+	if graphPkg != "" {
+		start := root
+		if !isSelf(graphPkg) {
+			var ok bool
+			if start, ok = dependencyOf(m, graphPkg); !ok {
+				return fmt.Errorf("%s is not vendored", graphPkg)
+			}
+		}
+		nodes = reachable(edges, start)
+	}
+
+	fmt.Println("digraph gvt {")
+	for _, n := range nodes {
+		to := keys(edges[n])
+		sort.Strings(to)
+		if len(to) == 0 {
+			fmt.Printf("\t%q;\n", n)
+		}
+		for _, t := range to {
+			fmt.Printf("\t%q -> %q;\n", n, t)
+		}
+	}
+	fmt.Println("}")
+	return nil
+}
+
+// dependencyGraph returns the name of the project node and the edges
+// between the project and the dependencies of m, from each importer to the
+// set of dependencies it imports.
+func dependencyGraph(m *vendor.Manifest, tests bool) (string, map[string]map[string]bool, error) {
+	root := selfImportpath()
+	if root == "" {
+		root = "project"
+	}
+
+	defer skipParseErrors()()
+	direct, err := vendor.ParseImports(projectDir, tests, vendorDir())
+	if err != nil {
+		return "", nil, fmt.Errorf("could not parse the project imports: %w", err)
+	}
+	pkgs, err := vendoredPackages(m)
+	if err != nil {
+		return "", nil, err
+	}
+
+	edges := make(map[string]map[string]bool)
+	addEdge := func(from, path string) {
+		to, ok := dependencyOf(m, path)
+		if !ok || to == from {
+			return
+		}
+		if edges[from] == nil {
+			edges[from] = make(map[string]bool)
+		}
+		edges[from][to] = true
+	}
+	for path := range direct {
+		if !isSelf(path) {
+			addEdge(root, path)
+		}
+	}
+	for path, p := range pkgs {
+		from, ok := dependencyOf(m, path)
+		if !ok {
+			continue
+		}
+		for _, i := range p.Imports {
+			addEdge(from, i)
+		}
+	}
+	return root, edges, nil
+}
+
+// dependencyOf returns the import path of the dependency of m containing
+// the package path. Nested dependencies take precedence over their parent.
+func dependencyOf(m *vendor.Manifest, path string) (string, bool) {
+	var dep string
+	for _, d := range m.Dependencies {
+		if path == d.Importpath || strings.HasPrefix(path, d.Importpath+"/") {
+			if len(d.Importpath) > len(dep) {
+				dep = d.Importpath
+			}
+		}
+	}
+	return dep, dep != ""
+}
+
+// reachable returns the nodes of edges reachable from start, start first
+// and the others sorted.
+func reachable(edges map[string]map[string]bool, start string) []string {
+	seen := map[string]bool{start: true}
+	queue := []string{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for t := range edges[n] {
+			if !seen[t] {
+				seen[t] = true
+				queue = append(queue, t)
+			}
+		}
+	}
+	delete(seen, start)
+	nodes := keys(seen)
+	sort.Strings(nodes)
+	return append([]string{start}, nodes...)
+}
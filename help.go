@@ -17,6 +17,94 @@ var helpTemplate = `usage: gvt {{.UsageLine}}
 {{.Long | trim}}
 `
 
+var topicTemplate = `{{.Long | trim}}
+`
+
+// helpTopics document the flags shared by several commands, which refer
+// to them instead of repeating them.
+var helpTopics = []*Command{helpNetwork, helpParsing}
+
+var helpNetwork = &Command{
+	Name:  "network",
+	Short: "flags of the commands accessing remote repositories",
+	Long: `The commands accessing remote repositories, fetch, rebuild, restore, update,
+import-lock, outdated and diff, accept these flags.
+
+	-retries n
+		retry a checkout failing because of a network error up to n
+		times, waiting longer after each attempt. Defaults to 0.
+	-retry-wait duration
+		how long to wait before the first retry, doubled after each one
+		up to a minute. Must not be negative. Defaults to 2s.
+	-timeout duration
+		how long to wait for the go-import metadata of a vanity import
+		path. Defaults to 30s.
+	-netrc file
+		read the credentials of private repositories from file instead
+		of $NETRC or ~/.netrc. They are used for git repositories and
+		go-import metadata over HTTPS.
+	-mirror prefix=mirror
+		clone the repositories whose URL, without the scheme, starts with
+		prefix from mirror instead, as in
+		"github.com=git.internal.example.com/mirror". The manifest keeps
+		the original repository. The longest matching prefix is used.
+		Can be supplied multiple times. The go-import metadata of vanity
+		import paths is still requested from their host.
+	-no-cache
+		neither read nor write the cache of the repositories deduced
+		from import paths, see cache.
+	-dep-timeout duration
+		give up on a checkout attempt after duration, killing the VCS
+		commands. It counts as a network error for -retries.
+	-deadline duration
+		give up on the whole command after duration.
+	-offline
+		never access the network, failing with the import path of the
+		first dependency which would need to be fetched. rebuild and
+		restore then only check that the dependencies are already
+		vendored and unmodified.
+	-vcs [prefix=]vcs
+		use the version control system vcs, one of git, hg, bzr or svn,
+		for the repositories of the import paths under prefix, or of all
+		of them, instead of trying in turn those their host could serve.
+		Import paths under prefix without go-import metadata are taken as
+		the root of their repository. Can be repeated.
+`,
+}
+
+var helpParsing = &Command{
+	Name:  "parsing",
+	Short: "flags of the commands parsing the imports of the project",
+	Long: `The commands parsing the imports of the project, prune, graph and why,
+accept these flags.
+
+	-follow-symlinks
+		also parse the directories symlinks in the project point to, each
+		only once. They are skipped otherwise.
+	-skip-errors
+		skip the files of the project which can't be parsed, warning
+		about each, instead of failing.
+	-provided prefix
+		treat the imports which are or are inside prefix as provided by
+		the platform, like the standard library. The App Engine SDK
+		imports "appengine" and "appengine_internal" are always
+		provided. Can be supplied multiple times.
+	-exclude-dir pattern
+		do not parse the project directories whose path, relative to the
+		project directory and slash separated, matches pattern, as in
+		"examples/*", nor anything inside them. Can be supplied multiple
+		times. The vendor directory, testdata directories and those
+		starting with a dot or an underscore are always skipped: patterns
+		only add to them, and skipping wins over -follow-symlinks.
+	-platforms list
+		evaluate the build constraints of each file for all the comma
+		separated GOOS/GOARCH pairs, as in "linux/amd64,windows/amd64",
+		and use the file if any of them matches, so that the imports
+		needed by every platform are considered. Can be supplied multiple times.
+		If not supplied only the host platform is considered.
+`,
+}
+
 // help implements the 'help' command.
 func help(args []string) {
 	if len(args) == 0 {
@@ -38,9 +126,11 @@ func help(args []string) {
 		tmpl(f, documentationTemplate, struct {
 			Usage    string
 			Commands []*Command
+			Topics   []*Command
 		}{
 			u.String(),
 			commands,
+			helpTopics,
 		})
 		f.Close()
 		return
@@ -52,6 +142,12 @@ func help(args []string) {
 			return
 		}
 	}
+	for _, topic := range helpTopics {
+		if topic.Name == arg {
+			tmpl(os.Stdout, topicTemplate, topic)
+			return
+		}
+	}
 
 	fmt.Fprintf(os.Stderr, "Unknown help topic %#q. Run 'gvt help'.\n", arg)
 	os.Exit(2)
@@ -63,10 +159,34 @@ Usage:
         gvt command [arguments]
 
 The commands are:
-{{range .}}
+{{range .Commands}}
         {{.Name | printf "%-11s"}} {{.Short}}{{end}}
 
+All commands accept -v to print debug messages and -q to only print errors.
+They also accept -target dir to use dir as the project directory instead of
+the current directory, and -vendor-dir dir to use dir, relative to the project
+directory, instead of vendor. It defaults to $GVT_VENDOR_DIR if set. The go
+tool only looks in directories named vendor. The files gvt reads from the
+current directory, like .gvtignore, are read from the project directory.
+
+The commands changing the vendor directory lock vendor/.gvt.lock, so that
+concurrent ones don't corrupt it, and fail if another gvt holds it. With
+-lock-timeout duration, which all commands accept, they wait up to duration
+for it instead.
+
+The exit status is 0 on success, 2 for bad flags or arguments, 3 if a
+repository could not be fetched, 4 if the vendor directory does not match
+the manifest, as reported by verify, status, and the -n flag of prune and
+clean, 5 if a Go file could not be parsed, 6 if the manifest could not be
+read or written, and 1 for any other error.
+
 Use "gvt help [command]" for more information about a command.
+
+Additional help topics:
+{{range .Topics}}
+        {{.Name | printf "%-11s"}} {{.Short}}{{end}}
+
+Use "gvt help [topic]" for more information about that topic.
 `
 
 var documentationTemplate = `// DO NOT EDIT THIS FILE.
@@ -82,6 +202,10 @@ Usage:
 
 {{.Long | trim}}
 
+{{end}}{{range .Topics}}{{.Short | capitalize}}
+
+{{.Long | trim}}
+
 {{end}}*/
 package main
 `
@@ -106,7 +230,13 @@ func capitalize(s string) string {
 
 func printUsage(w io.Writer) {
 	bw := bufio.NewWriter(w)
-	tmpl(bw, usageTemplate, commands)
+	tmpl(bw, usageTemplate, struct {
+		Commands []*Command
+		Topics   []*Command
+	}{
+		commands,
+		helpTopics,
+	})
 	bw.Flush()
 }
 
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignorefile lists, one per line, patterns of import paths that are never
+// fetched recursively. Blank lines and lines starting with # are skipped.
+const ignorefile = ".gvtignore"
+
+// ignored holds the patterns from -ignore and the ignore file.
+var ignored stringsFlag
+
+// stringsFlag is a flag.Value collecting the values of a repeated flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// loadIgnoreFile adds the patterns of the ignore file in the project
+// directory, if any, to ignored.
+func loadIgnoreFile() error {
+	f, err := os.Open(filepath.Join(projectDir, ignorefile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ignored = append(ignored, line)
+	}
+	return sc.Err()
+}
+
+// isIgnored reports whether importpath, or any of its parents, matches one
+// of the ignored patterns. Patterns use the syntax of path.Match.
+func isIgnored(importpath string) bool {
+	for _, pattern := range ignored {
+		for p := importpath; p != "." && p != "/"; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkIgnored returns an error if one of the ignored patterns is malformed.
+func checkIgnored() error {
+	for _, pattern := range ignored {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// self is the import path of the project, set with -self. If blank it is
+// deduced from the location of the project in GOPATH.
+var self string
+
+// selfImportpath returns the import path of the project, or "" if it is
+// unknown.
+func selfImportpath() string {
+	if self != "" {
+		return self
+	}
+	p, err := gopathImportpath(projectDir)
+	if err != nil {
+		return ""
+	}
+	return p
+}
+
+// isSelf reports whether importpath is the project or one of its packages,
+// including its internal ones, which must never be vendored.
+func isSelf(importpath string) bool {
+	s := selfImportpath()
+	return s != "" && (importpath == s || strings.HasPrefix(importpath, s+"/"))
+}
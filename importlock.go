@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var importTests bool // also import the dependencies only needed by tests
+
+func addImportLockFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&importTests, "tests", false, "import the dependencies of tests")
+	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.Var(insecureHostsFlag{}, "insecure-host", "host for which insecure protocols are allowed")
+	addNetworkFlags(fs)
+	fs.IntVar(&eventsFd, "events-fd", 0, "file descriptor to write JSON events to")
+}
+
+var cmdImportLock = &Command{
+	Name:      "import-lock",
+	UsageLine: "import-lock [-precaire] [-tests] [lockfile]",
+	Short:     "vendor the dependencies pinned by another tool",
+	Long: `import-lock vendors each repository pinned in the lock file of another
+vendoring tool, at the pinned revision, and records it in the manifest.
+
+The dependency graph is not resolved again: the lock file is trusted to
+be complete. Each repository is vendored from its root, so all the packages
+listed for it are included. Repositories already in the manifest are skipped.
+A repository that can't be fetched is reported, and the others are still
+imported.
+
+If lockfile is not supplied, the first lock file found in the current
+directory is read. Supported formats:
+
+	Gopkg.lock		dep
+	glide.lock		Glide
+	Godeps/Godeps.json	godep
+
+godep records packages rather than repositories. The repository of each of
+them is deduced when fetching, and it is only fetched once.
+
+Flags:
+	-tests
+		also import the dependencies only needed by tests, if the lock
+		file tells them apart.
+	-precaire
+		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: resolve, fetch-start, fetch-done, skip and error.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
+`,
+	Run: func(ctx context.Context, args []string) error {
+		var path string
+		switch len(args) {
+		case 0:
+			for _, name := range lockFiles {
+				p := filepath.Join(projectDir, filepath.FromSlash(name))
+				if _, err := os.Stat(p); err == nil {
+					path = p
+					break
+				}
+			}
+			if path == "" {
+				return fmt.Errorf("import-lock: no lock file found")
+			}
+		case 1:
+			path = args[0]
+		default:
+			return usageErrorf("import-lock: more than one lock file supplied")
+		}
+		deps, err := readLockFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %v", path, err)
+		}
+		return importLocked(ctx, deps)
+	},
+	AddFlags: addImportLockFlags,
+	Locks:    true,
+}
+
+// lockFiles are the lock files import-lock looks for, in order.
+var lockFiles = []string{"Gopkg.lock", "glide.lock", "Godeps/Godeps.json"}
+
+// readLockFile parses the lock file at path, picking the format from its
+// name.
+func readLockFile(path string) ([]vendor.LockedDependency, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch filepath.Base(path) {
+	case "Gopkg.lock":
+		return vendor.ParseGopkgLock(f)
+	case "glide.lock":
+		return vendor.ParseGlideLock(f)
+	case "Godeps.json":
+		return vendor.ParseGodeps(f)
+	default:
+		return nil, fmt.Errorf("unknown lock file format")
+	}
+}
+
+// importLocked vendors deps at their pinned revision, carrying on after a
+// failure and reporting all of them at the end.
+func importLocked(ctx context.Context, deps []vendor.LockedDependency) error {
+	summary.Start()
+
+	var errs multiError
+	for _, d := range deps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		m, err := vendor.ReadManifest(manifestFile())
+		if err != nil {
+			return fmt.Errorf("could not load manifest: %w", err)
+		}
+		if d.Test && !importTests {
+			debugf("skipping %s, it is only needed by tests", d.Importpath)
+			continue
+		}
+		if _, missing := missingDependency(m, d.Importpath); m.HasImportpath(d.Importpath) && !missing {
+			log.Printf("%s is already vendored, skipping", d.Importpath)
+			summary.Skip(d.Importpath)
+			continue
+		}
+		debugf("%s is locked to %s, packages %v", d.Importpath, d.Revision, d.Packages)
+
+		branch, tag, revision = "", "", d.Revision
+		log.Printf("fetching %s", d.Importpath)
+		if err := fetch(ctx, d.Importpath, false, true, false); err != nil {
+			log.Printf("could not import %s: %v", d.Importpath, err)
+			errs = append(errs, fmt.Errorf("%s: %w", d.Importpath, err))
+		}
+	}
+	if err := summary.Print(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var licenseAsJSON bool
+
+func addLicenseFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&licenseAsJSON, "json", false, "print the licenses as a JSON array")
+}
+
+var cmdLicense = &Command{
+	Name:      "license",
+	UsageLine: "license [-json]",
+	Short:     "list the licenses of vendored dependencies",
+	Long: `license looks for a LICENSE, COPYING or NOTICE file in the vendored directory
+of every dependency in the manifest and prints the license it detects, as an
+SPDX identifier such as MIT or Apache-2.0.
+
+Detection is a heuristic based on well known phrases of the license texts.
+Dependencies with a file whose license isn't recognized are reported as
+"unknown", those without any such file as "none", and those whose
+directory doesn't exist as "missing".
+
+Flags:
+	-json
+		print a JSON array of objects with the importpath, license and
+		file fields instead.
+
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("license takes no arguments")
+		}
+		return license()
+	},
+	AddFlags: addLicenseFlags,
+}
+
+type depLicense struct {
+	Importpath string `json:"importpath"`
+	License    string `json:"license"`
+	File       string `json:"file,omitempty"`
+}
+
+// licenses detects the license of every dependency in m.
+func licenses(m *vendor.Manifest) ([]depLicense, error) {
+	ls := []depLicense{}
+	for _, dep := range m.Dependencies {
+		dir := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
+		file, id, err := vendor.DetectLicense(dir)
+		if os.IsNotExist(err) {
+			ls = append(ls, depLicense{dep.Importpath, "missing", ""})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not detect the license of %s: %v", dep.Importpath, err)
+		}
+		if file == "" {
+			id = "none"
+		}
+		ls = append(ls, depLicense{dep.Importpath, id, file})
+	}
+	return ls, nil
+}
+
+func license() error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+	ls, err := licenses(m)
+	if err != nil {
+		return err
+	}
+
+	if licenseAsJSON {
+		buf, err := json.MarshalIndent(ls, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(buf))
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tLICENSE\tFILE")
+	for _, l := range ls {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", l.Importpath, l.License, l.File)
+	}
+	return w.Flush()
+}
+
+// allowedLicenses and deniedLicenses hold the SPDX identifiers passed to
+// fetch with -allow and -deny, each value may be a comma separated list.
+var allowedLicenses, deniedLicenses stringsFlag
+
+// checkLicense returns an error if the license detected in the first of
+// dirs holding a license file is denied, or not allowed when an allowlist
+// was supplied. Dependencies without a license file are reported as "none".
+func checkLicense(importpath string, dirs ...string) error {
+	if len(allowedLicenses) == 0 && len(deniedLicenses) == 0 {
+		return nil
+	}
+	id := "none"
+	for _, dir := range dirs {
+		file, l, err := vendor.DetectLicense(dir)
+		if err != nil {
+			return fmt.Errorf("could not detect the license of %s: %v", importpath, err)
+		}
+		if file != "" {
+			id = l
+			break
+		}
+	}
+	if containsLicense(deniedLicenses, id) {
+		return fmt.Errorf("%s: license %s is denied", importpath, id)
+	}
+	if len(allowedLicenses) > 0 && !containsLicense(allowedLicenses, id) {
+		return fmt.Errorf("%s: license %s is not allowed", importpath, id)
+	}
+	return nil
+}
+
+func containsLicense(list stringsFlag, id string) bool {
+	for _, v := range list {
+		for _, l := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(l), id) {
+				return true
+			}
+		}
+	}
+	return false
+}
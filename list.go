@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
@@ -11,16 +13,20 @@ import (
 )
 
 var (
-	format string
+	format     string
+	listAsJSON bool
+	listDirect bool // only list the dependencies which were asked for
 )
 
 func addListFlags(fs *flag.FlagSet) {
 	fs.StringVar(&format, "f", "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}", "format template")
+	fs.BoolVar(&listAsJSON, "json", false, "print the dependencies as a JSON array")
+	fs.BoolVar(&listDirect, "direct", false, "only list the direct dependencies")
 }
 
 var cmdList = &Command{
 	Name:      "list",
-	UsageLine: "list [-f format]",
+	UsageLine: "list [-f format | -json] [-direct]",
 	Short:     "list dependencies one per line",
 	Long: `list formats the contents of the manifest file.
 
@@ -28,19 +34,41 @@ Flags:
 	-f
 		controls the template used for printing each manifest entry. If not supplied
 		the default value is "{{.Importpath}}\t{{.Repository}}{{.Path}}\t{{.Branch}}\t{{.Revision}}"
+		The other fields of the manifest entries, like FetchedAt, the time
+		the dependency was last fetched or updated, can be used too.
+	-json
+		print the manifest entries as a single JSON array instead, using the
+		same field names as the manifest.
+	-direct
+		only list the direct dependencies, leaving out the ones fetched
+		recursively because another dependency imports them.
 
 `,
-	Run: func(args []string) error {
+	Run: func(ctx context.Context, args []string) error {
 		m, err := vendor.ReadManifest(manifestFile())
 		if err != nil {
-			return fmt.Errorf("could not load manifest: %v", err)
+			return fmt.Errorf("could not load manifest: %w", err)
+		}
+		deps := []vendor.Dependency{}
+		for _, dep := range m.Dependencies {
+			if !listDirect || !dep.Transitive {
+				deps = append(deps, dep)
+			}
+		}
+		if listAsJSON {
+			buf, err := json.MarshalIndent(deps, "", "\t")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Println(string(buf))
+			return err
 		}
 		tmpl, err := template.New("list").Parse(format)
 		if err != nil {
 			return fmt.Errorf("unable to parse template %q: %v", format, err)
 		}
 		w := tabwriter.NewWriter(os.Stdout, 1, 2, 1, ' ', 0)
-		for _, dep := range m.Dependencies {
+		for _, dep := range deps {
 			if err := tmpl.Execute(w, dep); err != nil {
 				return fmt.Errorf("unable to execute template: %v", err)
 			}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockTimeout is how long to wait for another gvt to release the lock of
+// the vendor directory, zero to fail immediately.
+var lockTimeout time.Duration
+
+// lockFile returns the path of the file locked while a command changes the
+// vendor directory. It is left in place, as removing it would let another
+// gvt lock a new one while the first is still held.
+func lockFile() string {
+	return filepath.Join(vendorDir(), ".gvt.lock")
+}
+
+// lockVendor takes the advisory lock of the vendor directory, waiting up to
+// lockTimeout if another gvt holds it, and returns the function releasing it.
+func lockVendor() (func(), error) {
+	if err := os.MkdirAll(vendorDir(), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(lockFile(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("could not lock %s: %v", lockFile(), err)
+		}
+		if ok {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			if lockTimeout > 0 {
+				return nil, fmt.Errorf("another gvt is still changing %s after %v, see -lock-timeout", vendorDir(), lockTimeout)
+			}
+			return nil, fmt.Errorf("another gvt is changing %s, use -lock-timeout to wait for it", vendorDir())
+		}
+		debugf("waiting for another gvt to release %s", lockFile())
+		time.Sleep(100 * time.Millisecond)
+	}
+	return func() {
+		// closing the file releases the lock
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package main
+
+import "os"
+
+// tryLock does nothing, there's no file locking on this platform.
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// Informational messages are printed with the log package, which -q
+// silences. Errors go through errLog instead, so they are always printed.
+
+var (
+	verbose bool // print debug messages
+	quiet   bool // only print errors
+)
+
+func addLogFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&verbose, "v", false, "print debug messages")
+	fs.BoolVar(&quiet, "q", false, "only print errors")
+}
+
+// errLog prints errors regardless of -q.
+var errLog = log.New(os.Stderr, "", log.LstdFlags)
+
+// setupLog applies -q to the log package. It must be called after the
+// flags are parsed.
+func setupLog() {
+	if quiet {
+		log.SetOutput(ioutil.Discard)
+	}
+}
+
+// debugf prints a message only if -v was supplied.
+func debugf(format string, args ...interface{}) {
+	if verbose {
+		log.Printf(format, args...)
+	}
+}
+
+// warnf prints a warning, unless -q was supplied.
+func warnf(format string, args ...interface{}) {
+	log.Printf("warning: "+format, args...)
+}
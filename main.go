@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+
+	"github.com/FiloSottile/gvt/gbvendor"
 )
 
 var fs = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
@@ -21,16 +26,36 @@ type Command struct {
 	UsageLine string
 	Short     string
 	Long      string
-	Run       func(args []string) error
+	Run       func(ctx context.Context, args []string) error
 	AddFlags  func(fs *flag.FlagSet)
+
+	// Locks is true if Run changes the vendor directory, which is then
+	// locked against other gvt invocations.
+	Locks bool
 }
 
 var commands = []*Command{
 	cmdFetch,
 	cmdRebuild,
+	cmdRestore,
 	cmdUpdate,
 	cmdList,
 	cmdDelete,
+	cmdVerify,
+	cmdStatus,
+	cmdRepair,
+	cmdMigrate,
+	cmdImportLock,
+	cmdPrune,
+	cmdClean,
+	cmdOutdated,
+	cmdDiff,
+	cmdLicense,
+	cmdGraph,
+	cmdWhy,
+	cmdCache,
+	cmdCompletion,
+	cmdVersion,
 }
 
 func main() {
@@ -49,34 +74,149 @@ func main() {
 		if command.Name == args[0] {
 
 			// add extra flags if necessary
+			addGlobalFlags(fs)
 			if command.AddFlags != nil {
 				command.AddFlags(fs)
 			}
 
 			if err := fs.Parse(args[1:]); err != nil {
-				log.Fatalf("could not parse flags: %v", err)
+				errLog.Printf("could not parse flags: %v", err)
+				os.Exit(exitUsage)
 			}
 			args = fs.Args() // reset args to the leftovers from fs.Parse
+			if err := checkNetworkFlags(); err != nil {
+				errLog.Print(err)
+				os.Exit(exitCode(err))
+			}
+			setupLog()
+			vendor.Debugf = debugf
+			vendor.Warnf = warnf
+			if err := loadNetrc(); err != nil {
+				errLog.Fatalf("could not load netrc: %v", err)
+			}
+			setupCache()
+			if err := setupEvents(); err != nil {
+				errLog.Fatal(err)
+			}
+
+			if err := setProjectDir(); err != nil {
+				errLog.Fatal(err)
+			}
+			unlock := func() {}
+			if command.Locks {
+				var err error
+				if unlock, err = lockVendor(); err != nil {
+					errLog.Fatal(err)
+				}
+			}
 
-			if err := command.Run(args); err != nil {
-				log.Fatalf("command %q failed: %v", command.Name, err)
+			ctx, stop := interruptContext()
+			if deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, deadline)
+				defer cancel()
+			}
+			err := command.Run(ctx, args)
+			if err != nil && ctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("deadline of %v exceeded", deadline)
+			}
+			stop()
+			unlock()
+			if err != nil {
+				errLog.Printf("command %q failed: %v", command.Name, err)
+				os.Exit(exitCode(err))
 			}
 			return
 		}
 	}
-	log.Fatalf("unknown command %q ", args[0])
+	errLog.Printf("unknown command %q ", args[0])
+	os.Exit(exitUsage)
 }
 
 const manifestfile = "manifest"
 
-func vendorDir() string {
-	wd, err := os.Getwd()
+// projectDir is the absolute path of the project, the directory holding
+// the vendor directory. It is set once before the command runs, so that
+// paths don't depend on the working directory changing afterwards.
+var projectDir string
+
+// addGlobalFlags adds the flags accepted by all commands.
+func addGlobalFlags(fs *flag.FlagSet) {
+	addLogFlags(fs)
+	fs.StringVar(&vendorDirFlag, "vendor-dir", os.Getenv("GVT_VENDOR_DIR"), "vendor directory")
+	fs.StringVar(&target, "target", "", "project directory")
+	fs.DurationVar(&lockTimeout, "lock-timeout", 0, "how long to wait for another gvt to release the vendor directory")
+}
+
+// target is the project directory set with -target. If blank it is the
+// working directory.
+var target string
+
+// setProjectDir sets projectDir from target or the working directory.
+func setProjectDir() error {
+	if target == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		projectDir = wd
+		return nil
+	}
+	dir, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(dir)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("invalid -target: %v", err)
 	}
-	return filepath.Join(wd, "vendor")
+	if !fi.IsDir() {
+		return fmt.Errorf("invalid -target: %s is not a directory", dir)
+	}
+	projectDir = dir
+	return nil
 }
 
+// vendorDirFlag is the vendor directory set with -vendor-dir or
+// GVT_VENDOR_DIR, relative to the project directory unless absolute.
+var vendorDirFlag string
+
+// vendorDir returns the absolute path of the vendor directory.
+func vendorDir() string {
+	switch {
+	case vendorDirFlag == "":
+		return filepath.Join(projectDir, "vendor")
+	case filepath.IsAbs(vendorDirFlag):
+		return filepath.Clean(vendorDirFlag)
+	default:
+		return filepath.Join(projectDir, vendorDirFlag)
+	}
+}
+
+// manifestFile returns the absolute path of the manifest.
 func manifestFile() string {
 	return filepath.Join(vendorDir(), manifestfile)
 }
+
+// interruptContext returns a context which is canceled on the first
+// interrupt, letting the command stop at the next dependency and leave the
+// manifest and vendor directory consistent. A second interrupt kills the
+// process as usual. stop must be called once the command returns.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		select {
+		case <-c:
+			log.Printf("interrupted, stopping after the current step, interrupt again to quit now")
+			signal.Stop(c)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(c)
+		cancel()
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, exitSuccess},
+		{"usage", usageErrorf("fetch: import path missing"), exitUsage},
+		{"fetch", fetchError(errors.New("could not clone")), exitFetch},
+		{"mismatch", mismatchErrorf("%d dependencies are not imported", 2), exitMismatch},
+		{"other", errors.New("dependency could not be deleted"), exitFailure},
+		{"same", multiError{fetchError(errors.New("a")), fetchError(errors.New("b"))}, exitFetch},
+		{"mixed", multiError{fetchError(errors.New("a")), errors.New("b")}, exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v): want %d, got %d", tt.name, tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestExitCodeParseManifest(t *testing.T) {
+	parse := &vendor.ParseError{Path: "main.go", Line: 1, Err: errors.New("expected 'package'")}
+	manifest := &vendor.ManifestError{Path: "vendor/manifest", Err: errors.New("unexpected EOF")}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"parse", fmt.Errorf("could not parse the project imports: %w", parse), exitParse},
+		{"manifest", fmt.Errorf("could not load manifest: %w", manifest), exitManifest},
+		{"same", multiError{parse, fmt.Errorf("b: %w", parse)}, exitParse},
+		{"mixed", multiError{parse, manifest}, exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v): want %d, got %d", tt.name, tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	cause := errors.New("could not clone")
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"usage", usageErrorf("rebuild takes no arguments"), exitUsage},
+		{"fetch", fetchError(cause), exitFetch},
+		{"mismatch", mismatchErrorf("vendor directory modified"), exitMismatch},
+		{"wrapped", fmt.Errorf("github.com/foo/bar: %w", fetchError(cause)), exitFetch},
+	}
+	for _, tt := range tests {
+		var e *exitError
+		if !errors.As(tt.err, &e) {
+			t.Errorf("%s: want an *exitError, got %#v", tt.name, tt.err)
+			continue
+		}
+		if e.code != tt.code {
+			t.Errorf("%s: want code %d, got %d", tt.name, tt.code, e.code)
+		}
+	}
+	if !errors.Is(fetchError(cause), cause) {
+		t.Error("fetchError: expected the cause to be unwrapped")
+	}
+	if fetchError(nil) != nil {
+		t.Error("fetchError(nil): expected nil")
+	}
+}
+
+func TestRebuildKeepGoing(t *testing.T) {
+	defer func(dir string) { projectDir = dir }(projectDir)
+	projectDir = t.TempDir()
+	defer func(k bool) { keepGoing = k }(keepGoing)
+	keepGoing = true
+	defer func(j, n int) { rbJobs, rbPerHost = j, n }(rbJobs, rbPerHost)
+	rbJobs, rbPerHost = 2, 2
+
+	// import paths which can't be deduced, without any network access
+	m := &vendor.Manifest{Dependencies: []vendor.Dependency{
+		{Importpath: "corporate/one", Repository: "https://corporate/one", Revision: "cafebad"},
+		{Importpath: "corporate/two", Repository: "https://corporate/two", Revision: "deadbeef"},
+	}}
+	if err := os.MkdirAll(vendorDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := vendor.WriteManifest(manifestFile(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	err := rebuild(context.Background(), false)
+	me, ok := err.(multiError)
+	if !ok || len(me) != 2 {
+		t.Fatalf("rebuild -keep-going: want the errors of both dependencies, got %#v", err)
+	}
+	for _, err := range me {
+		var e *exitError
+		if !errors.As(err, &e) || e.code != exitFetch {
+			t.Errorf("rebuild -keep-going: want a fetch error, got %#v", err)
+		}
+	}
+	if got := exitCode(err); got != exitFetch {
+		t.Errorf("rebuild -keep-going: want exit code %d, got %d", exitFetch, got)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	modulePath   string // module path of the project
+	removeVendor bool   // remove the vendor directory after migrating
+)
+
+func addMigrateFlags(fs *flag.FlagSet) {
+	fs.StringVar(&modulePath, "module", "", "module path of the project")
+	fs.BoolVar(&removeVendor, "remove-vendor", false, "remove the vendor directory")
+}
+
+var cmdMigrate = &Command{
+	Name:      "migrate",
+	UsageLine: "migrate [-module path] [-remove-vendor]",
+	Short:     "write a go.mod from the manifest",
+	Long: `migrate writes a go.mod file in the current directory requiring every
+dependency in the manifest at its recorded revision.
+
+Dependencies are grouped by the root of their repository, which becomes
+the module path. The manifest only records commit hashes, so those are
+written as the required versions. Run "go mod tidy" afterwards to have
+the go command turn them into canonical versions and write go.sum. A
+go.sum can't be derived from the vendored files, since it holds checksums
+of the module archives.
+
+migrate refuses to overwrite an existing go.mod. The vendor directory is
+left in place unless -remove-vendor is supplied, run "go mod vendor" to
+bring it in a state usable with -mod=vendor.
+
+Flags:
+	-module path
+		the module path of the project. If not supplied it is inferred from
+		the location of the current directory in GOPATH.
+	-remove-vendor
+		remove the vendor directory, including the manifest, once go.mod
+		is written.
+
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("migrate takes no arguments")
+		}
+		return migrate()
+	},
+	AddFlags: addMigrateFlags,
+	Locks:    true,
+}
+
+func migrate() error {
+	gomod := filepath.Join(projectDir, "go.mod")
+	if _, err := os.Stat(gomod); err == nil {
+		return fmt.Errorf("%s already exists", gomod)
+	}
+
+	mod := modulePath
+	if mod == "" {
+		var err error
+		mod, err = gopathImportpath(projectDir)
+		if err != nil {
+			return fmt.Errorf("%v, use -module to set the module path", err)
+		}
+	}
+
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+
+	requires := make(map[string]string)
+	for _, dep := range m.Dependencies {
+		root := strings.TrimSuffix(dep.Importpath, dep.Path)
+		if rev, ok := requires[root]; ok && rev != dep.Revision {
+			warnf("%s is vendored at both %s and %s, using %s", root, rev, dep.Revision, rev)
+			continue
+		}
+		requires[root] = dep.Revision
+	}
+	var roots []string
+	for root := range requires {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "module %s\n", mod)
+	if v := goVersion(); v != "" {
+		fmt.Fprintf(&buf, "\ngo %s\n", v)
+	}
+	if len(roots) > 0 {
+		fmt.Fprintf(&buf, "\nrequire (\n")
+		for _, root := range roots {
+			fmt.Fprintf(&buf, "\t%s %s\n", root, requires[root])
+		}
+		fmt.Fprintf(&buf, ")\n")
+	}
+	if err := ioutil.WriteFile(gomod, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	log.Printf("wrote %s with %d requirements, run \"go mod tidy\" to complete it", gomod, len(roots))
+
+	if removeVendor {
+		return vendor.RemoveAll(vendorDir())
+	}
+	return nil
+}
+
+// gopathImportpath returns the import path of dir, which must be inside
+// the src directory of one of the GOPATH entries.
+func gopathImportpath(dir string) (string, error) {
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		src := filepath.Join(gopath, "src")
+		rel, err := filepath.Rel(src, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		return filepath.ToSlash(rel), nil
+	}
+	return "", fmt.Errorf("%s is not inside GOPATH", dir)
+}
+
+var goVersionRe = regexp.MustCompile(`^go(1\.[0-9]+)`)
+
+// goVersion returns the language version of the running toolchain, as used
+// by the go directive, or "" if it is a development version.
+func goVersion() string {
+	v := goVersionRe.FindStringSubmatch(runtime.Version())
+	if v == nil {
+		return ""
+	}
+	return v[1]
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	minimal   bool     // only keep the packages the project imports
+	minimized []string // dependencies fetched with -minimal in this run
+)
+
+// minimizeFetched minimizes the dependencies fetched with -minimal, once
+// all of them are vendored, and writes the manifest.
+func minimizeFetched() error {
+	if len(minimized) == 0 {
+		return nil
+	}
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return err
+	}
+	for _, importpath := range minimized {
+		if err := minimizeDependency(m, importpath); err != nil {
+			return err
+		}
+	}
+	return vendor.WriteManifest(manifestFile(), m)
+}
+
+// minimizeDependency removes from the vendored copy of the dependency
+// importpath of m the packages which the project doesn't import, even
+// through other dependencies, and records the kept ones in m. The
+// dependency is left whole if none of its packages is imported yet.
+func minimizeDependency(m *vendor.Manifest, importpath string) error {
+	used, err := usedImports(m, true)
+	if err != nil {
+		return err
+	}
+	var kept []string
+	for path := range used {
+		if path != importpath && !strings.HasPrefix(path, importpath+"/") {
+			continue
+		}
+		if innermostDependency(m, path) != importpath {
+			continue
+		}
+		kept = append(kept, path)
+	}
+	if len(kept) == 0 {
+		debugf("not minimizing %s, none of its packages is imported", importpath)
+		return nil
+	}
+	sort.Strings(kept)
+
+	for i, dep := range m.Dependencies {
+		if dep.Importpath != importpath {
+			continue
+		}
+		dep.Packages = kept
+		if err := prunePackages(m, dep); err != nil {
+			return err
+		}
+		if dep.Checksum, err = checksum(m, importpath); err != nil {
+			return err
+		}
+		m.Dependencies[i] = dep
+	}
+	return nil
+}
+
+// prunePackages removes from the vendored copy of dep the packages which
+// are not in dep.Packages, leaving alone the dependencies of m nested
+// inside it.
+func prunePackages(m *vendor.Manifest, dep vendor.Dependency) error {
+	keep := make(map[string]bool)
+	for _, p := range dep.Packages {
+		keep[p] = true
+	}
+	var skip []string
+	for _, d := range m.Dependencies {
+		if strings.HasPrefix(d.Importpath, dep.Importpath+"/") {
+			skip = append(skip, filepath.Join(vendorDir(), filepath.FromSlash(d.Importpath)))
+		}
+	}
+	debugf("keeping only %s of %s", strings.Join(dep.Packages, ", "), dep.Importpath)
+	return vendor.PrunePackages(filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath)), dep.Importpath, keep, skip...)
+}
+
+// prunedDependency returns the dependency of m holding path if it was
+// minimized without path, so that fetching path only needs it restored
+// into the vendored copy.
+func prunedDependency(m *vendor.Manifest, path string) (vendor.Dependency, bool) {
+	root := innermostDependency(m, path)
+	if root == "" {
+		return vendor.Dependency{}, false
+	}
+	dep, err := m.GetDependencyForImportpath(root)
+	if err != nil || len(dep.Packages) == 0 {
+		return vendor.Dependency{}, false
+	}
+	for _, p := range dep.Packages {
+		if p == path {
+			return vendor.Dependency{}, false
+		}
+	}
+	return dep, true
+}
+
+// restorePackage copies the package path, pruned from the minimized
+// dependency dep of m, back into its vendored copy from a checkout at the
+// recorded revision, and records it among the kept packages.
+func restorePackage(ctx context.Context, m *vendor.Manifest, dep vendor.Dependency, path string) error {
+	log.Printf("%s was pruned from %s, fetching it again", path, dep.Importpath)
+	repo, _, err := vendor.DeduceRemoteRepoContext(ctx, dep.Importpath, insecure)
+	if err != nil {
+		return emitError(path, fetchError(err))
+	}
+	wc, err := checkout(ctx, repo, "", "", dep.Revision)
+	if err != nil {
+		return emitError(path, err)
+	}
+	defer wc.Destroy()
+
+	src := filepath.Join(wc.Dir(), filepath.FromSlash(dep.Path+path[len(dep.Importpath):]))
+	if dep.Pruned {
+		if err := vendor.PruneFiles(src); err != nil {
+			return emitError(path, err)
+		}
+	}
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return emitError(path, fmt.Errorf("%s is not in %s at %s: %v", path, dep.Importpath, dep.Revision, err))
+	}
+	// only the files of the package itself, its subdirectories are other
+	// packages, or dependencies nested inside dep
+	dst := filepath.Join(vendorDir(), filepath.FromSlash(path))
+	for _, fi := range files {
+		if fi.Mode().IsRegular() && !strings.HasPrefix(fi.Name(), ".") {
+			if err := vendor.Copypath(filepath.Join(dst, fi.Name()), filepath.Join(src, fi.Name())); err != nil {
+				return emitError(path, err)
+			}
+		}
+	}
+
+	m.RemoveDependency(dep)
+	dep.Packages = append(dep.Packages, path)
+	sort.Strings(dep.Packages)
+	if dep.Checksum, err = checksum(m, dep.Importpath); err != nil {
+		return err
+	}
+	if err := m.AddDependency(dep); err != nil {
+		return err
+	}
+	return vendor.WriteManifest(manifestFile(), m)
+}
+
+// innermostDependency returns the import path of the innermost dependency
+// of m holding path, or "" if none does.
+func innermostDependency(m *vendor.Manifest, path string) string {
+	var root string
+	for _, d := range m.Dependencies {
+		if (path == d.Importpath || strings.HasPrefix(path, d.Importpath+"/")) && len(d.Importpath) > len(root) {
+			root = d.Importpath
+		}
+	}
+	return root
+}
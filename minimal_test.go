@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+func TestFetchPrunedPackage(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skipf("git not found: %v", err)
+	}
+	defer func(dir string) { projectDir = dir }(projectDir)
+	projectDir = t.TempDir()
+
+	// example.com/lib is a local repository, found through the cache
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"lib.go":   "package lib\n",
+		"a/a.go":   "package a\n",
+		"b/b.go":   "package b\n",
+		"b/c/c.go": "package c\n",
+	})
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=gvt", "-c", "user.email=gvt@example.com"}, args...)...)
+		cmd.Dir = src
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	rev := git("rev-parse", "HEAD")
+
+	defer func(dir string) { vendor.CacheDir = dir }(vendor.CacheDir)
+	vendor.CacheDir = t.TempDir()
+	defer vendor.ClearCache()
+	buf, err := json.Marshal(map[string]interface{}{"example.com/lib": map[string]interface{}{
+		"vcs": "git", "url": "file://" + filepath.ToSlash(src), "time": time.Now(),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(vendor.CacheDir, "repos.json"), buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// fetched with -minimal, only example.com/lib/a was kept
+	writeFiles(t, vendorDir(), map[string]string{
+		"example.com/lib/a/a.go": "package a\n",
+	})
+	m := &vendor.Manifest{Dependencies: []vendor.Dependency{{
+		Importpath: "example.com/lib",
+		Repository: "file://" + filepath.ToSlash(src),
+		Revision:   rev,
+		Packages:   []string{"example.com/lib/a"},
+	}}}
+	if err := vendor.WriteManifest(manifestFile(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	// as fetchMissing does for a package imported later
+	if err := fetch(context.Background(), "example.com/lib/b", false, true, true); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	for name, want := range map[string]bool{
+		"example.com/lib/a/a.go":   true,
+		"example.com/lib/b/b.go":   true,
+		"example.com/lib/b/c/c.go": false,
+		"example.com/lib/lib.go":   false,
+	} {
+		_, err := os.Stat(filepath.Join(vendorDir(), filepath.FromSlash(name)))
+		if got := err == nil; got != want {
+			t.Errorf("%s: want vendored %v, got %v", name, want, got)
+		}
+	}
+	m, err = vendor.ReadManifest(manifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := m.GetDependencyForImportpath("example.com/lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com/lib/a", "example.com/lib/b"}; !reflect.DeepEqual(d.Packages, want) {
+		t.Errorf("want packages %q, got %q", want, d.Packages)
+	}
+	if sum, err := checksum(m, d.Importpath); err != nil || sum != d.Checksum {
+		t.Errorf("want checksum %s, got %s, %v", d.Checksum, sum, err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// netrcFile is the netrc file set with -netrc.
+var netrcFile string
+
+// loadNetrc adds the credentials of the netrc file to vendor.Credentials.
+// Unless one was set with -netrc, $NETRC or ~/.netrc is read if it exists.
+func loadNetrc() error {
+	path := netrcFile
+	if path == "" {
+		path = os.Getenv("NETRC")
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+		path = filepath.Join(home, name)
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && netrcFile == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	creds, err := vendor.ParseNetrc(f)
+	if err != nil {
+		return err
+	}
+	for host, c := range creds {
+		vendor.Credentials[host] = c
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+func addOutdatedFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.Var(insecureHostsFlag{}, "insecure-host", "host for which insecure protocols are allowed")
+	addNetworkFlags(fs)
+}
+
+var cmdOutdated = &Command{
+	Name:      "outdated",
+	UsageLine: "outdated [-precaire] [-insecure-host host]",
+	Short:     "list dependencies with newer upstream revisions",
+	Long: `outdated checks the head of the branch each dependency was fetched from and
+prints the dependencies in the manifest along with their current and latest
+revision. The vendor directory and the manifest are left untouched.
+
+Dependencies fetched with -tag or -revision can't be updated and are
+reported as "pinned", and the ones replaced with fetch -replace as
+"replaced". A dependency whose repository can't be checked is
+reported as "error", the other ones are still listed.
+
+Flags:
+	-precaire
+		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("outdated takes no arguments")
+		}
+		return outdated(ctx)
+	},
+	AddFlags: addOutdatedFlags,
+}
+
+func outdated(ctx context.Context) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+
+	// dependencies from the same repository and branch share a checkout
+	type head struct{ repository, branch string }
+	latest := make(map[head]string)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tCURRENT\tLATEST\tBEHIND")
+	var failed int
+	for _, dep := range m.Dependencies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if dep.Replace != "" {
+			fmt.Fprintf(w, "%s\t\treplaced\t\n", dep.Importpath)
+			continue
+		}
+		if dep.Hoisted != "" {
+			fmt.Fprintf(w, "%s\t\thoisted\t\n", dep.Importpath)
+			continue
+		}
+		if dep.Branch == "HEAD" {
+			fmt.Fprintf(w, "%s\t%s\tpinned\t\n", dep.Importpath, dep.Revision)
+			continue
+		}
+		h := head{dep.Repository, dep.Branch}
+		rev, ok := latest[h]
+		if !ok {
+			rev, err = latestRevision(ctx, dep)
+			if err != nil {
+				log.Printf("could not check %s: %v", dep.Importpath, err)
+				fmt.Fprintf(w, "%s\t%s\terror\t\n", dep.Importpath, dep.Revision)
+				failed++
+				continue
+			}
+			latest[h] = rev
+		}
+		behind := "no"
+		if rev != dep.Revision {
+			behind = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", dep.Importpath, dep.Revision, rev, behind)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fetchError(fmt.Errorf("%d dependencies could not be checked", failed))
+	}
+	return nil
+}
+
+// latestRevision checks out the head of the branch dep was fetched from and
+// returns its revision.
+func latestRevision(ctx context.Context, dep vendor.Dependency) (string, error) {
+	repo, _, err := vendor.DeduceRemoteRepoContext(ctx, dep.Importpath, insecure)
+	if err != nil {
+		return "", fetchError(err)
+	}
+
+	debugf("checking out %s", repo.URL())
+	wc, err := checkout(ctx, repo, dep.Branch, "", "")
+	if err != nil {
+		return "", err
+	}
+	defer wc.Destroy()
+
+	return wc.Revision()
+}
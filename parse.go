@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var skipErrors bool // skip the project files which can't be parsed
+
+// addParseFlags adds the flags of the commands parsing the imports of the
+// project.
+func addParseFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&vendor.FollowSymlinks, "follow-symlinks", false, "parse the directories symlinks point to")
+	fs.BoolVar(&skipErrors, "skip-errors", false, "skip the files which can't be parsed")
+	fs.Var(providedFlag{}, "provided", "prefix of import paths provided by the platform")
+	fs.Var(excludeDirFlag{}, "exclude-dir", "pattern of project directories not to parse")
+	fs.Var(platformsFlag{}, "platforms", "comma separated GOOS/GOARCH pairs to consider")
+}
+
+// platformsFlag is a flag.Value adding each comma separated GOOS/GOARCH
+// pair to vendor.Platforms.
+type platformsFlag struct{}
+
+func (platformsFlag) String() string { return strings.Join(vendor.Platforms, ",") }
+
+func (platformsFlag) Set(value string) error {
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if !platformRe.MatchString(p) {
+			return fmt.Errorf("expected GOOS/GOARCH, got %q", p)
+		}
+		vendor.Platforms = append(vendor.Platforms, p)
+	}
+	return nil
+}
+
+var platformRe = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9]+$`)
+
+// excludeDirFlag is a flag.Value adding each pattern to vendor.ExcludeDirs.
+type excludeDirFlag struct{}
+
+func (excludeDirFlag) String() string { return strings.Join(vendor.ExcludeDirs, ",") }
+
+func (excludeDirFlag) Set(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	vendor.ExcludeDirs = append(vendor.ExcludeDirs, strings.Trim(pattern, "/"))
+	return nil
+}
+
+// providedFlag is a flag.Value adding each prefix to vendor.ProvidedImports.
+type providedFlag struct{}
+
+func (providedFlag) String() string { return strings.Join(vendor.ProvidedImports, ",") }
+
+func (providedFlag) Set(prefix string) error {
+	vendor.ProvidedImports = append(vendor.ProvidedImports, strings.TrimSuffix(prefix, "/"))
+	return nil
+}
+
+// skipParseErrors makes the parsing of the project imports skip, and warn
+// about, the files which can't be parsed if -skip-errors was supplied. The
+// returned function warns again about all of them, to be called once done.
+func skipParseErrors() func() {
+	if !skipErrors {
+		return func() {}
+	}
+	var skipped []string
+	vendor.SkipParseError = func(path string, err error) {
+		warnf("%v, skipping the file", err)
+		skipped = append(skipped, path)
+	}
+	return func() {
+		vendor.SkipParseError = nil
+		if len(skipped) > 0 {
+			warnf("%d files could not be parsed and were skipped:\n\t%s", len(skipped), strings.Join(skipped, "\n\t"))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// progress keeps a status line, like the number of dependencies fetched so
+// far, at the bottom of the terminal. Log output is printed above it.
+// A nil *progress is valid and prints nothing, it is what startProgress
+// returns when standard error is not a terminal, so that piped output stays
+// line oriented, or when -q was supplied.
+type progress struct {
+	mu     sync.Mutex
+	status string
+}
+
+// startProgress returns a progress writing to standard error and routes the
+// log package through it, or nil if it should stay silent.
+func startProgress() *progress {
+	if quiet {
+		return nil
+	}
+	fi, err := os.Stderr.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	p := new(progress)
+	log.SetOutput(p)
+	return p
+}
+
+// Write clears the status line, writes b and draws the status line again.
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(os.Stderr, "\r\x1b[K")
+	n, err := os.Stderr.Write(b)
+	fmt.Fprint(os.Stderr, p.status)
+	return n, err
+}
+
+// Set replaces the status line.
+func (p *progress) Set(format string, args ...interface{}) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = fmt.Sprintf(format, args...)
+	fmt.Fprint(os.Stderr, "\r\x1b[K", p.status)
+}
+
+// Stop clears the status line and restores the log output.
+func (p *progress) Stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = ""
+	fmt.Fprint(os.Stderr, "\r\x1b[K")
+	log.SetOutput(os.Stderr)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	pruneDryRun bool // only print what would be removed
+	pruneTests  bool // consider the imports of tests as used
+)
+
+func addPruneFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&pruneDryRun, "n", false, "print the dependencies that would be removed")
+	fs.BoolVar(&pruneTests, "tests", false, "keep the dependencies of tests")
+	fs.StringVar(&self, "self", "", "import path of the project")
+	addParseFlags(fs)
+}
+
+var cmdPrune = &Command{
+	Name:      "prune",
+	UsageLine: "prune [-n] [-tests] [-self importpath] [-follow-symlinks] [-skip-errors] [-provided prefix] [-exclude-dir pattern] [-platforms list]",
+	Short:     "remove dependencies that are not imported",
+	Long: `prune removes from the vendor directory and the manifest the dependencies
+that are not needed by the project any more.
+
+A dependency is needed if any of its packages is imported by the source of
+the project, outside the vendor directory, or by another needed dependency.
+Imports of the project's own packages don't count, so a copy of the project
+in its own vendor directory is removed. The import path of each removed
+dependency is printed.
+
+A dependency that needed packages were hoisted out of by fetch -flatten is
+kept, as they are fetched again through it. The packages hoisted out of a
+removed dependency are removed with it.
+
+Flags:
+	-n
+		only print the dependencies that would be removed, and exit with
+		status 4 if there are any.
+	-tests
+		keep the dependencies needed by the tests of the project.
+	-self importpath
+		the import path of the project. If not supplied it is deduced from
+		the location of the project in GOPATH.
+
+The parsing flags, like -provided and -platforms, are also accepted, see
+"gvt help parsing".
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("prune takes no arguments")
+		}
+		return prune()
+	},
+	AddFlags: addPruneFlags,
+	Locks:    true,
+}
+
+func prune() error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+
+	used, err := usedImports(m, pruneTests)
+	if err != nil {
+		return err
+	}
+
+	// the dependencies used packages were hoisted from are kept, as they
+	// are needed to fetch them again
+	hoisting := make(map[string]bool)
+	for _, dep := range m.Dependencies {
+		if isUsed(used, dep.Importpath) {
+			for _, path := range hoistedFrom(m, dep) {
+				hoisting[path] = true
+			}
+		}
+	}
+
+	var unused []vendor.Dependency
+	for _, dep := range m.Dependencies {
+		if !isUsed(used, dep.Importpath) && !hoisting[dep.Importpath] {
+			unused = append(unused, dep)
+		}
+	}
+
+	for _, dep := range unused {
+		fmt.Println(dep.Importpath)
+		if pruneDryRun {
+			continue
+		}
+		if _, err := m.GetDependencyForImportpath(dep.Importpath); err != nil {
+			// hoisted from a dependency removed already
+			continue
+		}
+		if err := removeHoisted(m, dep.Importpath); err != nil {
+			return err
+		}
+		if err := m.RemoveDependency(dep); err != nil {
+			return fmt.Errorf("dependency could not be deleted: %v", err)
+		}
+		if err := vendor.RemoveAll(filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))); err != nil {
+			// TODO(dfc) need to apply vendor.cleanpath here to remove indermediate directories.
+			return fmt.Errorf("dependency could not be deleted: %v", err)
+		}
+	}
+	switch {
+	case len(unused) == 0:
+		return nil
+	case pruneDryRun:
+		return mismatchErrorf("%d dependencies are not imported", len(unused))
+	}
+	return vendor.WriteManifest(manifestFile(), m)
+}
+
+// usedImports returns the set of import paths reachable from the source
+// of the project, following the imports of the vendored packages of m.
+// If tests is true the imports of the project tests are included. The
+// packages of roots, and those inside them, are considered used as well.
+func usedImports(m *vendor.Manifest, tests bool, roots ...string) (map[string]bool, error) {
+	defer skipParseErrors()()
+	direct, err := vendor.ParseImports(projectDir, tests, vendorDir())
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the project imports: %w", err)
+	}
+	pkgs, err := vendoredPackages(m)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	var walk func(string)
+	walk = func(path string) {
+		if used[path] || isSelf(path) {
+			return
+		}
+		used[path] = true
+		p, ok := pkgs[path]
+		if !ok {
+			return
+		}
+		for _, i := range p.Imports {
+			walk(i)
+		}
+	}
+	for path := range direct {
+		walk(path)
+	}
+	for _, root := range roots {
+		walk(root)
+		for path := range pkgs {
+			if strings.HasPrefix(path, root+"/") {
+				walk(path)
+			}
+		}
+	}
+	return used, nil
+}
+
+// vendoredPackages loads the packages of every dependency of m from the
+// vendor directory, keyed by import path.
+func vendoredPackages(m *vendor.Manifest) (map[string]*vendor.Pkg, error) {
+	var paths []struct{ Root, Prefix string }
+	for _, d := range m.Dependencies {
+		dir := filepath.Join(vendorDir(), filepath.FromSlash(d.Importpath))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		paths = append(paths, struct{ Root, Prefix string }{dir, filepath.FromSlash(d.Importpath)})
+	}
+	dsm, err := vendor.LoadPaths(paths...)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make(map[string]*vendor.Pkg)
+	for _, s := range dsm {
+		for path, p := range s.Pkgs {
+			pkgs[path] = p
+		}
+	}
+	return pkgs, nil
+}
+
+// isUsed reports whether used contains importpath or a package inside it.
+func isUsed(used map[string]bool, importpath string) bool {
+	for path := range used {
+		if path == importpath || strings.HasPrefix(path, importpath+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -1,26 +1,39 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/FiloSottile/gvt/gbvendor"
 )
 
 var (
 	rbInsecure bool // Allow the use of insecure protocols
+	rbJobs     int  // number of dependencies to fetch concurrently
+	rbPerHost  int  // number of dependencies to fetch concurrently from a host
 )
 
 func addRebuildFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&rbInsecure, "precaire", false, "allow the use of insecure protocols")
+	fs.Var(insecureHostsFlag{}, "insecure-host", "host for which insecure protocols are allowed")
+	fs.IntVar(&rbJobs, "j", 1, "number of dependencies to fetch concurrently")
+	fs.IntVar(&rbPerHost, "per-host", 2, "number of dependencies to fetch concurrently from each host")
+	fs.BoolVar(&keepGoing, "keep-going", false, "keep fetching after a dependency fails")
+	addNetworkFlags(fs)
+	fs.IntVar(&eventsFd, "events-fd", 0, "file descriptor to write JSON events to")
 }
 
 var cmdRebuild = &Command{
 	Name:      "rebuild",
-	UsageLine: "rebuild",
+	UsageLine: "rebuild [-j n] [-per-host n] [-keep-going]",
 	Short:     "rebuild dependencies from manifest",
 	Long: `rebuild fetches the dependencies listed in the manifest.
 
@@ -32,58 +45,447 @@ source, for example if .gitignore includes lines like
 
 Note that such a setup requires "gvt rebuild" to build the source, relies on
 the availability of the dependencies repositories and breaks "go get".
+All the dependencies are fetched again, restore only fetches the missing
+ones.
+
+Dependencies replaced with fetch -replace are copied, or symlinked, again
+from their local directory. Packages hoisted with fetch -flatten are moved
+again out of the vendor directory of the dependency they came from. Only
+the packages recorded by fetch -minimal are kept.
 
 Flags:
+	-j n
+		fetch up to n dependencies concurrently. Defaults to 1.
+		Dependencies vendored from the same repository at the same
+		revision share a single checkout.
+	-per-host n
+		fetch up to n dependencies concurrently from the same host,
+		so that -j doesn't get a single host to throttle the clones.
+		Dependencies from different hosts can still use all of -j.
+		Defaults to 2.
+	-keep-going
+		when a dependency fails to fetch, carry on with the others and
+		report all the failures at the end.
 	-precaire
 		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: resolve, fetch-start, fetch-done, skip and error.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
 `,
-	Run: func(args []string) error {
+	Run: func(ctx context.Context, args []string) error {
 		switch len(args) {
 		case 0:
-			return rebuild()
+			return rebuild(ctx, false)
 		default:
-			return fmt.Errorf("rebuild takes no arguments")
+			return usageErrorf("rebuild takes no arguments")
 		}
 	},
 	AddFlags: addRebuildFlags,
+	Locks:    true,
 }
 
-func rebuild() error {
+// rebuild fetches the dependencies of the manifest at their recorded
+// revision. If present is true, those already vendored and unmodified are
+// left alone.
+func rebuild(ctx context.Context, present bool) error {
 	m, err := vendor.ReadManifest(manifestFile())
 	if err != nil {
-		return fmt.Errorf("could not load manifest: %v", err)
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+
+	if rbJobs < 1 {
+		return usageErrorf("-j must be at least 1")
+	}
+	if rbPerHost < 1 {
+		return usageErrorf("-per-host must be at least 1")
+	}
+
+	if vendor.Offline {
+		return rebuildOffline(m)
 	}
 
+	// done is closed once the dependency has been copied into place, so
+	// that dependencies nested inside it are only copied afterwards.
+	done := make(map[string]chan struct{})
 	for _, dep := range m.Dependencies {
-		dst := filepath.Join(vendorDir(), dep.Importpath)
-		if _, err := os.Stat(dst); err == nil {
-			if err := vendor.RemoveAll(dst); err != nil {
-				// TODO need to apply vendor.cleanpath here too
-				return fmt.Errorf("dependency could not be deleted: %v", err)
+		done[dep.Importpath] = make(chan struct{})
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    multiError
+		fetched int
+		sem     = make(chan struct{}, rbJobs)
+		hosts   = newHostLimiter(rbPerHost)
+	)
+
+	// stale records the dependencies to fetch again even if present, as
+	// the packages hoisted from them are missing and only placed when
+	// they are copied in place
+	stale := make(map[string]bool)
+	if present {
+		for _, dep := range m.Dependencies {
+			if dep.Hoisted == "" {
+				continue
+			}
+			ok, err := isPresent(m, dep)
+			if err != nil {
+				return fmt.Errorf("%s: %w", dep.Importpath, err)
+			}
+			if !ok {
+				from := hoistedFrom(m, dep)
+				debugf("%s is missing, fetching %s again", dep.Importpath, from[len(from)-1])
+				stale[from[len(from)-1]] = true
 			}
 		}
+	}
+
+	shared := newCheckouts(m.Dependencies)
+	defer shared.Close()
+
+	p := startProgress()
+	defer p.Stop()
+
+	for _, dep := range m.Dependencies {
+		wg.Add(1)
+		go func(dep vendor.Dependency) {
+			defer wg.Done()
+			defer close(done[dep.Importpath])
+			if dep.Hoisted != "" {
+				// moved in place when rebuilding dep.Hoisted, which
+				// replaces the dependencies nested inside it, so they
+				// wait for that too
+				if c, ok := done[dep.Hoisted]; ok {
+					<-c
+				}
+				mu.Lock()
+				fetched++
+				mu.Unlock()
+				return
+			}
+
+			for path, c := range done {
+				if strings.HasPrefix(dep.Importpath, path+"/") {
+					<-c
+				}
+			}
+
+			// checked once the dependencies it's nested in are in place,
+			// as fetching them again replaces it
+			if present && !stale[dep.Importpath] {
+				ok, err := isPresent(m, dep)
+				if err != nil || ok {
+					mu.Lock()
+					if err != nil {
+						errs = append(errs, fmt.Errorf("%s: %w", dep.Importpath, err))
+					}
+					fetched++
+					mu.Unlock()
+					if ok {
+						debugf("%s is already vendored", dep.Importpath)
+						emitSkip(dep.Importpath, "already vendored")
+					}
+					return
+				}
+			}
+
+			// wait for the host first, not to hold a slot of -j that
+			// dependencies from other hosts could use
+			defer hosts.Acquire(repoHost(dep.Repository))()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			failed := (len(errs) > 0 && !keepGoing) || ctx.Err() != nil
+			if !failed {
+				p.Set("fetched %d/%d, fetching %s", fetched, len(m.Dependencies), dep.Importpath)
+			}
+			mu.Unlock()
+			if failed {
+				return
+			}
 
-		log.Printf("fetching %s", dep.Importpath)
+			start := time.Now()
+			emit(event{Event: "fetch-start", Importpath: dep.Importpath, Repository: dep.Repository})
+			err := rebuildDependency(ctx, dep, shared, rbInsecure)
+			if err == nil && dep.Flattened {
+				err = placeHoisted(m, dep)
+			}
+			if err == nil && len(dep.Packages) > 0 {
+				err = prunePackages(m, dep)
+			}
+			if err != nil {
+				emitError(dep.Importpath, err)
+			} else {
+				emit(event{Event: "fetch-done", Importpath: dep.Importpath, Repository: dep.Repository,
+					Revision: dep.Revision, Duration: time.Since(start)})
+			}
+
+			mu.Lock()
+			if err != nil {
+				if keepGoing {
+					log.Printf("could not fetch %s: %v", dep.Importpath, err)
+				}
+				errs = append(errs, fmt.Errorf("%s: %w", dep.Importpath, err))
+			}
+			fetched++
+			mu.Unlock()
+		}(dep)
+	}
+	wg.Wait()
 
-		repo, _, err := vendor.DeduceRemoteRepo(dep.Importpath, rbInsecure)
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case len(errs) == 0:
+		return nil
+	case keepGoing:
+		return errs
+	default:
+		return errs[0]
+	}
+}
+
+// rebuildOffline checks that the dependencies of m are already vendored
+// and unmodified, as none can be fetched, and copies the replaced ones.
+func rebuildOffline(m *vendor.Manifest) error {
+	for _, dep := range m.Dependencies {
+		if dep.Replace != "" {
+			log.Printf("copying %s from %s", dep.Importpath, dep.Replace)
+			if err := placeReplacement(dep); err != nil {
+				return err
+			}
+			continue
+		}
+		dir := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return fetchError(fmt.Errorf("%s is missing, and fetching it needs the network, but running offline", dep.Importpath))
+		}
+		if dep.Checksum == "" {
+			continue
+		}
+		sum, err := checksum(m, dep.Importpath)
 		if err != nil {
-			return err
+			return fmt.Errorf("could not checksum %s: %v", dep.Importpath, err)
 		}
+		if sum != dep.Checksum {
+			return fetchError(fmt.Errorf("%s is modified, and fetching it needs the network, but running offline", dep.Importpath))
+		}
+		debugf("%s is already vendored", dep.Importpath)
+	}
+	return nil
+}
 
-		wc, err := repo.Checkout("", "", dep.Revision)
+// isPresent reports whether dep is vendored, and unmodified if the manifest
+// records its checksum.
+func isPresent(m *vendor.Manifest, dep vendor.Dependency) (bool, error) {
+	dir := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return false, nil
+	}
+	if dep.Checksum == "" {
+		return true, nil
+	}
+	sum, err := checksum(m, dep.Importpath)
+	if err != nil {
+		return false, fmt.Errorf("could not checksum %s: %v", dep.Importpath, err)
+	}
+	return sum == dep.Checksum, nil
+}
+
+// rebuildDependency fetches dep at its recorded revision and copies it
+// into the vendor directory, replacing any existing copy. The checkout is
+// shared with the other dependencies of the same repository and revision.
+func rebuildDependency(ctx context.Context, dep vendor.Dependency, shared *checkouts, insecure bool) error {
+	if dep.Replace != "" {
+		log.Printf("copying %s from %s", dep.Importpath, dep.Replace)
+		return placeReplacement(dep)
+	}
+
+	log.Printf("fetching %s", dep.Importpath)
+
+	wc, err := shared.Get(dep, func() (vendor.WorkingCopy, error) {
+		repo, _, err := vendor.DeduceRemoteRepoContext(ctx, dep.Importpath, insecure)
 		if err != nil {
-			return err
+			return nil, fetchError(err)
 		}
+		debugf("checking out %s at %s", repo.URL(), dep.Revision)
+		return checkout(ctx, repo, "", "", dep.Revision)
+	})
+	if err != nil {
+		return err
+	}
 
-		src := filepath.Join(wc.Dir(), dep.Path)
-		if err := vendor.Copypath(dst, src); err != nil {
-			return err
+	// the existing copy is only removed once the checkout succeeded, so
+	// that a failed or interrupted fetch leaves it in place.
+	dst := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
+	if _, err := os.Stat(dst); err == nil {
+		if err := vendor.RemoveAll(dst); err != nil {
+			// TODO need to apply vendor.cleanpath here too
+			return fmt.Errorf("dependency could not be deleted: %v", err)
 		}
+	}
 
-		if err := wc.Destroy(); err != nil {
+	src := filepath.Join(wc.Dir(), filepath.FromSlash(dep.Path))
+	if dep.Pruned {
+		if err := vendor.PruneFiles(src); err != nil {
 			return err
 		}
 	}
+	if err := vendor.Copypath(dst, src); err != nil {
+		return err
+	}
+
+	return shared.Release(dep)
+}
+
+// hostLimiter limits how many dependencies are fetched concurrently from
+// each host. It is safe for concurrent use.
+type hostLimiter struct {
+	n    int
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+func newHostLimiter(n int) *hostLimiter {
+	return &hostLimiter{n: n, sems: make(map[string]chan struct{})}
+}
+
+// Acquire waits until a dependency can be fetched from host, and returns
+// the function to call once done. Dependencies without a host, like the
+// replaced ones, are not limited.
+func (l *hostLimiter) Acquire(host string) func() {
+	if host == "" {
+		return func() {}
+	}
+	l.mu.Lock()
+	sem, ok := l.sems[host]
+	if !ok {
+		sem = make(chan struct{}, l.n)
+		l.sems[host] = sem
+	}
+	l.mu.Unlock()
+	sem <- struct{}{}
+	return func() { <-sem }
+}
+
+// repoHost returns the host of the repository URL repository, including
+// scp style git ones like git@github.com:foo/bar, or repository itself if
+// it has none. Local file URLs have no host.
+func repoHost(repository string) string {
+	switch u, err := url.Parse(repository); {
+	case err == nil && u.Host != "":
+		return u.Hostname()
+	case err == nil && u.Scheme == "file":
+		return ""
+	}
+	host := repository
+	if i := strings.Index(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
 
+// checkoutKey identifies the dependencies which can share a checkout.
+// Pruned dependencies modify it, so they don't share it with the others.
+type checkoutKey struct {
+	repository, revision string
+	pruned               bool
+}
+
+func keyOf(dep vendor.Dependency) checkoutKey {
+	return checkoutKey{dep.Repository, dep.Revision, dep.Pruned}
+}
+
+// sharedCheckout is a working copy used by one or more dependencies.
+type sharedCheckout struct {
+	ready   chan struct{} // closed once wc and err are set
+	started bool
+	wc      vendor.WorkingCopy
+	err     error
+	users   int
+}
+
+// checkouts deduplicates the checkouts of concurrent rebuilds, so that
+// each repository is only cloned once at a given revision even if several
+// dependencies are vendored from it.
+type checkouts struct {
+	mu sync.Mutex
+	m  map[checkoutKey]*sharedCheckout
+}
+
+// newCheckouts returns a set of checkouts to be used by deps, each of
+// which must call Release once done with its working copy.
+func newCheckouts(deps []vendor.Dependency) *checkouts {
+	c := &checkouts{m: make(map[checkoutKey]*sharedCheckout)}
+	for _, dep := range deps {
+		s := c.m[keyOf(dep)]
+		if s == nil {
+			s = &sharedCheckout{ready: make(chan struct{})}
+			c.m[keyOf(dep)] = s
+		}
+		s.users++
+	}
+	return c
+}
+
+// Get returns the working copy shared by dep, calling create to check it
+// out if no other dependency did. Concurrent callers wait for the first.
+func (c *checkouts) Get(dep vendor.Dependency, create func() (vendor.WorkingCopy, error)) (vendor.WorkingCopy, error) {
+	c.mu.Lock()
+	s := c.m[keyOf(dep)]
+	first := !s.started
+	s.started = true
+	c.mu.Unlock()
+
+	if first {
+		s.wc, s.err = create()
+		close(s.ready)
+	}
+	<-s.ready
+	return s.wc, s.err
+}
+
+// Release marks dep as done with its working copy, which is destroyed
+// when no other dependency needs it anymore.
+func (c *checkouts) Release(dep vendor.Dependency) error {
+	c.mu.Lock()
+	s := c.m[keyOf(dep)]
+	s.users--
+	last := s.users == 0
+	if last {
+		delete(c.m, keyOf(dep))
+	}
+	c.mu.Unlock()
+
+	if last && s.wc != nil {
+		return s.wc.Destroy()
+	}
 	return nil
 }
+
+// Close destroys the working copies left by dependencies which failed or
+// were skipped before releasing them.
+func (c *checkouts) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, s := range c.m {
+		if s.started {
+			<-s.ready
+			if s.wc != nil {
+				s.wc.Destroy()
+			}
+		}
+		delete(c.m, k)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// writeFiles writes the files, relative to dir, with their contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRebuildNestedInHoisted(t *testing.T) {
+	defer func(dir string) { projectDir = dir }(projectDir)
+	projectDir = t.TempDir()
+	defer func(j, n int) { rbJobs, rbPerHost = j, n }(rbJobs, rbPerHost)
+	rbJobs, rbPerHost = 4, 2
+
+	// example.com/outer vendors example.com/hoisted, which was flattened,
+	// and example.com/hoisted/nested is vendored separately inside it
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"outer/outer.go": "package outer\n",
+		"outer/vendor/example.com/hoisted/hoisted.go": "package hoisted\n",
+		"nested/nested.go": "package nested\n",
+	})
+	m := &vendor.Manifest{Dependencies: []vendor.Dependency{{
+		Importpath: "example.com/outer",
+		Replace:    filepath.Join(src, "outer"),
+		Flattened:  true,
+	}, {
+		Importpath: "example.com/hoisted",
+		Transitive: true,
+		Flattened:  true,
+		Hoisted:    "example.com/outer",
+	}, {
+		Importpath: "example.com/hoisted/nested",
+		Replace:    filepath.Join(src, "nested"),
+	}}}
+	if err := os.MkdirAll(vendorDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := vendor.WriteManifest(manifestFile(), m); err != nil {
+		t.Fatal(err)
+	}
+
+	// the order the dependencies are copied in depends on scheduling
+	for i := 0; i < 20; i++ {
+		if err := rebuild(context.Background(), false); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{
+			"example.com/outer/outer.go",
+			"example.com/hoisted/hoisted.go",
+			"example.com/hoisted/nested/nested.go",
+		} {
+			if _, err := os.Stat(filepath.Join(vendorDir(), filepath.FromSlash(name))); err != nil {
+				t.Fatalf("rebuild %d: %v", i, err)
+			}
+		}
+	}
+}
+
+func TestRestoreHoisted(t *testing.T) {
+	defer func(dir string) { projectDir = dir }(projectDir)
+	projectDir = t.TempDir()
+	defer func(j, n int) { rbJobs, rbPerHost = j, n }(rbJobs, rbPerHost)
+	rbJobs, rbPerHost = 1, 2
+
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"outer/outer.go": "package outer\n",
+		"outer/vendor/example.com/hoisted/hoisted.go": "package hoisted\n",
+	})
+	m := &vendor.Manifest{Dependencies: []vendor.Dependency{{
+		Importpath: "example.com/outer",
+		Replace:    filepath.Join(src, "outer"),
+		Flattened:  true,
+	}, {
+		Importpath: "example.com/hoisted",
+		Transitive: true,
+		Flattened:  true,
+		Hoisted:    "example.com/outer",
+	}}}
+	if err := os.MkdirAll(vendorDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := vendor.WriteManifest(manifestFile(), m); err != nil {
+		t.Fatal(err)
+	}
+	if err := rebuild(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the hoisted package is gone, but the dependency it was hoisted from
+	// is still present
+	if err := os.RemoveAll(filepath.Join(vendorDir(), "example.com", "hoisted")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rebuild(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"example.com/outer/outer.go",
+		"example.com/hoisted/hoisted.go",
+	} {
+		if _, err := os.Stat(filepath.Join(vendorDir(), filepath.FromSlash(name))); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(vendorDir(), "example.com", "outer", "vendor")); !os.IsNotExist(err) {
+		t.Errorf("want the vendor directory of example.com/outer removed, got %v", err)
+	}
+}
+
+func TestRepoHost(t *testing.T) {
+	tests := []struct {
+		repository, want string
+	}{
+		{"https://github.com/foo/bar", "github.com"},
+		{"https://github.com:8443/foo/bar", "github.com"},
+		{"https://user@example.com/foo", "example.com"},
+		{"ssh://git@github.com/foo/bar", "github.com"},
+		{"ssh://git@github.com:2222/foo/bar", "github.com"},
+		{"git@github.com:foo/bar", "github.com"},
+		{"github.com:foo/bar", "github.com"},
+		{"file:///tmp/foo", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := repoHost(tt.repository); got != tt.want {
+			t.Errorf("repoHost(%q): want %q, got %q", tt.repository, tt.want, got)
+		}
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	const perHost, jobs = 2, 8
+	l := newHostLimiter(perHost)
+	var (
+		mu      sync.Mutex
+		running = make(map[string]int)
+		max     = make(map[string]int)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < jobs; i++ {
+		for _, host := range []string{"github.com", "example.com", ""} {
+			wg.Add(1)
+			go func(host string) {
+				defer wg.Done()
+				defer l.Acquire(host)()
+				mu.Lock()
+				running[host]++
+				if running[host] > max[host] {
+					max[host] = running[host]
+				}
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				running[host]--
+				mu.Unlock()
+			}(host)
+		}
+	}
+	wg.Wait()
+	for _, host := range []string{"github.com", "example.com"} {
+		if max[host] != perHost {
+			t.Errorf("%s: want at most %d concurrent fetches, got %d", host, perHost, max[host])
+		}
+	}
+	if max[""] <= perHost {
+		t.Errorf("want the fetches without a host unlimited, got at most %d concurrent", max[""])
+	}
+}
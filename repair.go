@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var repairForce bool // replace a manifest which can still be read
+
+func addRepairFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&repairForce, "f", false, "replace a manifest which can still be read")
+}
+
+var cmdRepair = &Command{
+	Name:      "repair",
+	UsageLine: "repair [-f]",
+	Short:     "rebuild a lost manifest from the vendor directory",
+	Long: `repair writes a new manifest describing the dependencies found in the vendor
+directory, for when the manifest was lost or corrupted but the vendored
+files are intact.
+
+The directories holding files are grouped into dependencies by repository
+root. For github.com, bitbucket.org, gitlab.com and golang.org/x that is the
+first three elements of the import path, for gopkg.in the elements up to
+the version, as in gopkg.in/yaml.v2, otherwise it is the top most directory
+holding files. A directory with git metadata is always a dependency of its own, and
+its revision, branch and remote are read from it.
+
+Vendored files don't usually carry VCS metadata, so the revision of most
+dependencies will be unknown. They are listed in a warning, and recorded
+with their checksum so that verify still detects changes. Use update or
+fetch to record a known revision again.
+
+Flags:
+	-f
+		replace the manifest even if it can still be read.
+
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("repair takes no arguments")
+		}
+		return repair()
+	},
+	AddFlags: addRepairFlags,
+	Locks:    true,
+}
+
+func repair() error {
+	if m, err := vendor.ReadManifest(manifestFile()); err == nil && len(m.Dependencies) > 0 && !repairForce {
+		return usageErrorf("the manifest can be read, use -f to replace it")
+	}
+
+	roots, err := repairRoots()
+	if err != nil {
+		return err
+	}
+	if len(roots) == 0 {
+		return fmt.Errorf("no vendored files found in %s", vendorDir())
+	}
+
+	m := new(vendor.Manifest)
+	var unknown []string
+	for _, importpath := range roots {
+		dep := vendor.Dependency{
+			Importpath: importpath,
+			Repository: "https://" + importpath,
+		}
+		dir := filepath.Join(vendorDir(), filepath.FromSlash(importpath))
+		if wc, ok := vendor.LocalGitClone(dir); ok {
+			if rev, err := wc.Revision(); err == nil {
+				dep.Revision = rev
+			}
+			if b, err := wc.Branch(); err == nil {
+				dep.Branch = b
+			}
+			if url, err := wc.Remote(); err == nil && url != "" {
+				dep.Repository = url
+			}
+		}
+		if dep.Revision == "" {
+			unknown = append(unknown, importpath)
+		}
+		if err := m.AddDependency(dep); err != nil {
+			return fmt.Errorf("could not add %s: %v", importpath, err)
+		}
+	}
+
+	// checksums exclude nested dependencies, so they need all of them
+	for i, dep := range m.Dependencies {
+		sum, err := checksum(m, dep.Importpath)
+		if err != nil {
+			return fmt.Errorf("could not checksum %s: %v", dep.Importpath, err)
+		}
+		m.Dependencies[i].Checksum = sum
+	}
+
+	if err := vendor.WriteManifest(manifestFile(), m); err != nil {
+		return fmt.Errorf("could not write manifest: %v", err)
+	}
+	log.Printf("recorded %d dependencies", len(m.Dependencies))
+	if len(unknown) > 0 {
+		warnf("the revision of %d dependencies is unknown:\n\t%s", len(unknown), strings.Join(unknown, "\n\t"))
+	}
+	return nil
+}
+
+// repairRoots returns the import paths of the dependencies found in the
+// vendor directory, in order.
+func repairRoots() ([]string, error) {
+	root := vendorDir()
+	var dirs []string        // directories holding files
+	git := map[string]bool{} // directories holding git metadata
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.Name() == ".git" {
+				git[filepath.ToSlash(filepath.Dir(rel))] = true
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// the manifest, and files of other tools, live at the root
+		if !info.IsDir() && filepath.Dir(path) != root {
+			dirs = append(dirs, filepath.ToSlash(filepath.Dir(rel)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+	for p := range git {
+		found[p] = true
+	}
+	for _, dir := range dirs {
+		found[repairRoot(dir, git)] = true
+	}
+	roots := keys(found)
+	sort.Strings(roots)
+
+	// only keep the top most of the roots without git metadata
+	var result []string
+	var last string
+	for _, r := range roots {
+		if !git[r] && last != "" && strings.HasPrefix(r, last+"/") {
+			continue
+		}
+		if !git[r] {
+			last = r
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// repairRoot returns the import path of the repository root of the
+// vendored directory dir, preferring the closest root with git metadata.
+func repairRoot(dir string, git map[string]bool) string {
+	for p := dir; p != "."; p = filepath.ToSlash(filepath.Dir(p)) {
+		if git[p] {
+			return p
+		}
+	}
+	parts := strings.Split(dir, "/")
+	n := len(parts) // elements of the root
+	switch {
+	case parts[0] == "github.com", parts[0] == "bitbucket.org", parts[0] == "gitlab.com":
+		n = 3
+	case parts[0] == "golang.org" && len(parts) > 1 && parts[1] == "x":
+		n = 3
+	case parts[0] == "gopkg.in" && len(parts) > 1 && strings.Contains(parts[1], ".v"):
+		n = 2 // gopkg.in/pkg.v3
+	case parts[0] == "gopkg.in":
+		n = 3 // gopkg.in/user/pkg.v3
+	}
+	if len(parts) > n {
+		return strings.Join(parts[:n], "/")
+	}
+	return dir
+}
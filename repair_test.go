@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepairRoots(t *testing.T) {
+	defer func(dir string) { projectDir = dir }(projectDir)
+	projectDir = t.TempDir()
+	writeFiles(t, vendorDir(), map[string]string{
+		"manifest": "{}\n",
+
+		// sibling subpackages of known hosts share their repository
+		"github.com/foo/bar/a/a.go":          "package a\n",
+		"github.com/foo/bar/b/c/c.go":        "package c\n",
+		"golang.org/x/net/context/ctx.go":    "package context\n",
+		"golang.org/x/net/http2/http2.go":    "package http2\n",
+		"golang.org/x/net/http2/hpack/h.go":  "package hpack\n",
+		"gopkg.in/yaml.v2/yaml.go":           "package yaml\n",
+		"gopkg.in/check.v1/sub/sub.go":       "package sub\n",
+		"gopkg.in/user/pkg.v3/sub/sub.go":    "package sub\n",
+		"gopkg.in/user/pkg.v3/other/o.go":    "package other\n",
+		"bitbucket.org/foo/bar/baz/baz.go":   "package baz\n",
+		"example.com/top/top.go":             "package top\n",
+		"example.com/top/sub/sub.go":         "package sub\n",
+		"example.com/git/.git/HEAD":          "ref: refs/heads/master\n",
+		"example.com/git/sub/sub.go":         "package sub\n",
+		"example.com/git/nested/.git/HEAD":   "ref: refs/heads/master\n",
+		"example.com/git/nested/n.go":        "package nested\n",
+		"example.com/split/a/a.go":           "package a\n",
+		"example.com/split/b/b.go":           "package b\n",
+		"github.com/foo/bar/.hidden/x.go":    "package x\n",
+		"github.com/foo/hidden/.dir/file.go": "package file\n",
+	})
+
+	got, err := repairRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"bitbucket.org/foo/bar",
+		"example.com/git",
+		"example.com/git/nested",
+		// without files above them, unknown hosts can't be grouped
+		"example.com/split/a",
+		"example.com/split/b",
+		"example.com/top",
+		"github.com/foo/bar",
+		"golang.org/x/net",
+		"gopkg.in/check.v1",
+		"gopkg.in/user/pkg.v3",
+		"gopkg.in/yaml.v2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want roots\n\t%q\ngot\n\t%q", want, got)
+	}
+}
+
+func TestRepairRootsEmpty(t *testing.T) {
+	defer func(dir string) { projectDir = dir }(projectDir)
+	projectDir = t.TempDir()
+	got, err := repairRoots()
+	if err != nil || len(got) != 0 {
+		t.Errorf("want no roots, got %q, %v", got, err)
+	}
+}
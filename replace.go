@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// replacefile lists, one per line, replacements as importpath=dir, like
+// -replace. Blank lines and lines starting with # are skipped.
+const replacefile = ".gvtreplace"
+
+var (
+	replacements   = make(pinFlag) // local directories of dependencies
+	replaceSymlink bool            // symlink replacements instead of copying them
+)
+
+// loadReplaceFile adds the replacements of the replace file in the project
+// directory, if any, to replacements. Those from -replace take precedence.
+func loadReplaceFile() error {
+	f, err := os.Open(filepath.Join(projectDir, replacefile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	file := make(pinFlag)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := file.Set(line); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	for path, dir := range file {
+		if _, ok := replacements[path]; !ok {
+			replacements[path] = dir
+		}
+	}
+	return nil
+}
+
+// replacement returns the replaced import path holding importpath, the
+// longest one if several do, and the absolute path of its local directory.
+// Relative directories are relative to the project directory.
+func replacement(importpath string) (root, dir string, ok bool) {
+	for path, d := range replacements {
+		if importpath != path && !strings.HasPrefix(importpath, path+"/") {
+			continue
+		}
+		if len(path) > len(root) {
+			root, dir, ok = path, d, true
+		}
+	}
+	if !ok {
+		return "", "", false
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(projectDir, dir)
+	}
+	return root, filepath.Clean(dir), true
+}
+
+// placeReplacement copies the local directory of the replaced dependency
+// dep into the vendor directory, or symlinks it, replacing any existing
+// copy.
+func placeReplacement(dep vendor.Dependency) error {
+	fi, err := os.Stat(dep.Replace)
+	if err != nil {
+		return fmt.Errorf("replacement of %s: %v", dep.Importpath, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("replacement of %s: %s is not a directory", dep.Importpath, dep.Replace)
+	}
+
+	dst := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
+	if _, err := os.Lstat(dst); err == nil {
+		if err := vendor.RemoveAll(dst); err != nil {
+			return fmt.Errorf("dependency could not be deleted: %v", err)
+		}
+	}
+	if !dep.Symlink {
+		return vendor.Copypath(dst, dep.Replace)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(dep.Replace, dst)
+}
+
+// fetchReplacement vendors the replaced import path root from dir.
+func fetchReplacement(m *vendor.Manifest, root, dir string, transitive bool) error {
+	if m.HasImportpath(root) {
+		return fmt.Errorf("%s is already vendored", root)
+	}
+	if c, ok := caseCollision(m, root); ok && caseInsensitive {
+		return fmt.Errorf("%s would overwrite %s on a case-insensitive file system", root, c)
+	}
+
+	debugf("replacing %s with %s", root, dir)
+	dep := vendor.Dependency{
+		Importpath: root,
+		Replace:    dir,
+		Symlink:    replaceSymlink,
+		Transitive: transitive,
+		FetchedAt:  now(),
+	}
+	start := time.Now()
+	emit(event{Event: "fetch-start", Importpath: root})
+	if err := placeReplacement(dep); err != nil {
+		return emitError(root, err)
+	}
+	if err := summary.Add(root, filepath.Join(vendorDir(), filepath.FromSlash(root))); err != nil {
+		return err
+	}
+	if err := m.AddDependency(dep); err != nil {
+		return err
+	}
+	if err := vendor.WriteManifest(manifestFile(), m); err != nil {
+		return err
+	}
+	emit(event{Event: "fetch-done", Importpath: root, Duration: time.Since(start)})
+	return nil
+}
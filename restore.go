@@ -0,0 +1,55 @@
+package main
+
+import "context"
+
+var cmdRestore = &Command{
+	Name:      "restore",
+	UsageLine: "restore [-j n] [-per-host n] [-keep-going]",
+	Short:     "fetch the dependencies missing from the vendor directory",
+	Long: `restore fetches the dependencies listed in the manifest which are not
+already vendored, recreating the vendor directory of a fresh checkout which
+only includes the manifest.
+
+Like rebuild, each dependency is fetched at the revision the manifest
+records, without looking at the imports of the project or resolving its
+dependencies again. Unlike rebuild, dependencies already present in the
+vendor directory, with the checksum the manifest records if any, are left
+alone, so running restore again does nothing. A dependency is fetched again
+if packages hoisted from it with fetch -flatten are missing, to put them back.
+
+Flags:
+	-j n
+		fetch up to n dependencies concurrently. Defaults to 1.
+		Dependencies vendored from the same repository at the same
+		revision share a single checkout.
+	-per-host n
+		fetch up to n dependencies concurrently from the same host,
+		so that -j doesn't get a single host to throttle the clones.
+		Dependencies from different hosts can still use all of -j.
+		Defaults to 2.
+	-keep-going
+		when a dependency fails to fetch, carry on with the others and
+		report all the failures at the end.
+	-precaire
+		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: fetch-start, fetch-done, skip and error.
+
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
+`,
+	Run: func(ctx context.Context, args []string) error {
+		switch len(args) {
+		case 0:
+			return rebuild(ctx, true)
+		default:
+			return usageErrorf("restore takes no arguments")
+		}
+	},
+	AddFlags: addRebuildFlags,
+	Locks:    true,
+}
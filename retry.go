@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var (
+	retries    int           // number of times to retry a failed checkout
+	retryWait  time.Duration // wait before the first retry, doubled after each one
+	depTimeout time.Duration // timeout of each checkout attempt, if not zero
+	deadline   time.Duration // timeout of the whole command, if not zero
+)
+
+// addNetworkFlags adds the flags of the commands accessing remote
+// repositories.
+func addNetworkFlags(fs *flag.FlagSet) {
+	fs.IntVar(&retries, "retries", 0, "number of times to retry a checkout failing because of the network")
+	fs.DurationVar(&retryWait, "retry-wait", 2*time.Second, "wait before the first retry")
+	fs.DurationVar(&vendor.MetadataTimeout, "timeout", vendor.MetadataTimeout, "timeout of each request for vanity import metadata")
+	fs.StringVar(&netrcFile, "netrc", "", "netrc file to read credentials from")
+	fs.Var(mirrorFlag{}, "mirror", "mirror to clone repositories from, as prefix=mirror")
+	fs.BoolVar(&noCache, "no-cache", false, "do not use the repository cache")
+	fs.DurationVar(&depTimeout, "dep-timeout", 0, "timeout of each checkout attempt")
+	fs.DurationVar(&deadline, "deadline", 0, "timeout of the whole command")
+	fs.BoolVar(&vendor.Offline, "offline", false, "fail instead of accessing the network")
+	fs.Var(vcsFlag{}, "vcs", "version control system of the repositories, as [prefix=]vcs")
+}
+
+// maxRetryWait is the longest wait between two attempts of a checkout.
+const maxRetryWait = time.Minute
+
+// checkNetworkFlags rejects the values of the network flags checkout
+// can't use.
+func checkNetworkFlags() error {
+	if retryWait < 0 {
+		return usageErrorf("-retry-wait must not be negative")
+	}
+	return nil
+}
+
+// nextWait returns the wait before the retry following one after wait,
+// doubled up to maxRetryWait, or wait if already longer.
+func nextWait(wait time.Duration) time.Duration {
+	switch {
+	case wait > maxRetryWait:
+		return wait
+	case wait > maxRetryWait/2:
+		return maxRetryWait
+	}
+	return wait * 2
+}
+
+// checkout calls repo.Checkout, retrying up to retries times with
+// exponential backoff and jitter if it fails because of a network error
+// or because it took longer than depTimeout. No new attempt is made once
+// ctx is canceled, and the VCS commands of the current one are killed.
+func checkout(ctx context.Context, repo vendor.RemoteRepo, branch, tag, revision string) (vendor.WorkingCopy, error) {
+	if vendor.Offline {
+		return nil, fetchError(fmt.Errorf("fetching %s needs the network, but running offline", repo.URL()))
+	}
+	wait := retryWait
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		wc, timedOut, err := checkoutAttempt(ctx, repo, branch, tag, revision)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil || attempt > retries || !(timedOut || vendor.IsTemporary(err)) {
+			return wc, fetchError(err)
+		}
+		d := wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		log.Printf("fetching %s failed: %v, retrying in %v (%d/%d)", repo.URL(), err, d, attempt, retries)
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		wait = nextWait(wait)
+	}
+}
+
+// checkoutAttempt calls repo.Checkout once, reporting whether it failed
+// because it ran longer than depTimeout.
+func checkoutAttempt(ctx context.Context, repo vendor.RemoteRepo, branch, tag, revision string) (vendor.WorkingCopy, bool, error) {
+	if depTimeout <= 0 {
+		wc, err := vendor.CheckoutContext(ctx, repo, branch, tag, revision)
+		return wc, false, err
+	}
+	actx, cancel := context.WithTimeout(ctx, depTimeout)
+	defer cancel()
+	wc, err := vendor.CheckoutContext(actx, repo, branch, tag, revision)
+	if err != nil && ctx.Err() == nil && actx.Err() == context.DeadlineExceeded {
+		return nil, true, fmt.Errorf("timed out after %v", depTimeout)
+	}
+	return wc, false, err
+}
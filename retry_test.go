@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextWait(t *testing.T) {
+	for _, tt := range []struct {
+		wait, want time.Duration
+	}{
+		{0, 0},
+		{2 * time.Second, 4 * time.Second},
+		{20 * time.Second, 40 * time.Second},
+		{40 * time.Second, maxRetryWait},
+		{maxRetryWait, maxRetryWait},
+		{time.Hour, time.Hour},
+	} {
+		if got := nextWait(tt.wait); got != tt.want {
+			t.Errorf("nextWait(%v): want %v, got %v", tt.wait, tt.want, got)
+		}
+	}
+
+	// the jittered wait of checkout must not overflow after many retries
+	wait := 2 * time.Second
+	for i := 0; i < 100; i++ {
+		wait = nextWait(wait)
+	}
+	if wait != maxRetryWait {
+		t.Errorf("after 100 retries: want %v, got %v", maxRetryWait, wait)
+	}
+}
+
+func TestCheckNetworkFlags(t *testing.T) {
+	defer func(w time.Duration) { retryWait = w }(retryWait)
+	retryWait = -2 * time.Second
+	if err := checkNetworkFlags(); exitCode(err) != exitUsage {
+		t.Errorf("-retry-wait -2s: want a usage error, got %v", err)
+	}
+	retryWait = 0
+	if err := checkNetworkFlags(); err != nil {
+		t.Errorf("-retry-wait 0: %v", err)
+	}
+}
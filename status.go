@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var cmdStatus = &Command{
+	Name:      "status",
+	UsageLine: "status",
+	Short:     "compare the vendor directory with the manifest",
+	Long: `status reports the differences between the manifest and the vendor directory.
+
+It prints the same lines as verify, "missing", "modified", "unverified" and
+"replaced", followed by an "untracked" line for each directory of the vendor
+directory holding files that are not part of any dependency in the manifest.
+
+status does not access the network. It exits with status 4 if anything
+but unverified or replaced dependencies is reported.
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("status takes no arguments")
+		}
+		return status()
+	},
+}
+
+func status() error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+
+	failed, err := verifyDependencies(m)
+	if err != nil {
+		return err
+	}
+	untracked, err := untrackedDirs(m)
+	if err != nil {
+		return err
+	}
+	for _, dir := range untracked {
+		fmt.Printf("untracked\t%s\n", dir)
+	}
+
+	if n := failed + len(untracked); n > 0 {
+		return mismatchErrorf("%d differences between the manifest and the vendor directory", n)
+	}
+	return nil
+}
+
+// untrackedDirs returns the import paths of the top most directories of the
+// vendor directory holding files outside the dependencies of m.
+func untrackedDirs(m *vendor.Manifest) ([]string, error) {
+	deps := make(map[string]bool)
+	for _, d := range m.Dependencies {
+		deps[d.Importpath] = true
+	}
+
+	root := vendorDir()
+	var untracked []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+			return err
+		}
+		if path == root || info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if deps[filepath.ToSlash(rel)] {
+			// a replaced dependency symlinked to its local directory
+			return nil
+		}
+		// the manifest, and files of other tools, live at the root
+		if strings.HasPrefix(info.Name(), ".") || filepath.Dir(path) == root {
+			return nil
+		}
+		rel, err = filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		importpath := filepath.ToSlash(rel)
+		for p := importpath; p != "."; p = filepath.ToSlash(filepath.Dir(p)) {
+			if deps[p] {
+				return nil
+			}
+		}
+		untracked = append(untracked, importpath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// only keep the top most directories
+	sort.Strings(untracked)
+	var dirs []string
+	for _, dir := range untracked {
+		if n := len(dirs); n > 0 && (dir == dirs[n-1] || strings.HasPrefix(dir, dirs[n-1]+"/")) {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
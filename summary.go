@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var summaryAsJSON bool // print the fetch summary as JSON
+
+// fetchSummary collects statistics about a fetch. It is safe for
+// concurrent use.
+type fetchSummary struct {
+	mu       sync.Mutex
+	start    time.Time
+	counted  map[string]bool // dependencies counted as vendored or present
+	Vendored int             `json:"vendored"` // dependencies fetched
+	Present  int             `json:"present"`  // dependencies needed but already vendored
+	Bytes    int64           `json:"bytes"`    // bytes written to the vendor directory
+	Duration time.Duration   `json:"duration"` // wall clock time, in nanoseconds
+}
+
+var summary fetchSummary
+
+func (s *fetchSummary) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start = time.Now()
+	s.counted = make(map[string]bool)
+}
+
+// Add records the dependency importpath vendored in dir.
+func (s *fetchSummary) Add(importpath, dir string) error {
+	n, err := dirSize(dir)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counted[importpath] = true
+	s.Vendored++
+	s.Bytes += n
+	return nil
+}
+
+// Skip records the dependency importpath as needed but already vendored,
+// unless it was already counted.
+func (s *fetchSummary) Skip(importpath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counted[importpath] {
+		return
+	}
+	s.counted[importpath] = true
+	s.Present++
+}
+
+// Print prints the summary, as JSON on stdout with -json or to the log.
+func (s *fetchSummary) Print() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Duration = time.Since(s.start)
+	if summaryAsJSON {
+		buf, err := json.MarshalIndent(s, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(buf))
+		return err
+	}
+	log.Printf("vendored %d dependencies, %d already present, %d bytes written in %v",
+		s.Vendored, s.Present, s.Bytes, s.Duration.Round(time.Millisecond))
+	return nil
+}
+
+// dirSize returns the total size of the files in dir.
+func dirSize(dir string) (int64, error) {
+	var n int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			n += info.Size()
+		}
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+func TestFetchSummary(t *testing.T) {
+	var s fetchSummary
+	s.Start()
+	if err := s.Add("example.com/a", t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	// example.com/a was vendored by this run, example.com/b is needed
+	// twice but counted once
+	s.Skip("example.com/a")
+	s.Skip("example.com/b")
+	s.Skip("example.com/b")
+	if s.Vendored != 1 || s.Present != 1 {
+		t.Errorf("want 1 vendored and 1 present, got %d and %d", s.Vendored, s.Present)
+	}
+}
+
+func TestImportedFrom(t *testing.T) {
+	a := depset(map[string][2][]string{
+		"example.com/a": {[]string{"example.com/b"}, []string{"example.com/t"}},
+	})
+	b := depset(map[string][2][]string{
+		"example.com/b": {[]string{"example.com/c"}, []string{"example.com/bt"}},
+	})
+	dsm := map[string]*vendor.Depset{"a": a, "b": b}
+	for _, tt := range []struct {
+		tests bool
+		want  []string
+	}{
+		{false, []string{"example.com/a", "example.com/b", "example.com/c"}},
+		{true, []string{"example.com/a", "example.com/b", "example.com/c", "example.com/t"}},
+	} {
+		got := importedFrom(pkgs(a.Pkgs), dsm, tt.tests)
+		if len(got) != len(tt.want) {
+			t.Errorf("tests %v: want %v, got %v", tt.tests, tt.want, got)
+			continue
+		}
+		for _, p := range tt.want {
+			if !got[p] {
+				t.Errorf("tests %v: want %v, got %v", tt.tests, tt.want, got)
+			}
+		}
+	}
+}
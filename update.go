@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/FiloSottile/gvt/gbvendor"
 )
@@ -14,40 +19,71 @@ var (
 
 func addUpdateFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&updateAll, "all", false, "update all dependencies")
+	fs.StringVar(&revision, "revision", "", "update to the given revision instead of the head of the branch")
 	fs.BoolVar(&insecure, "precaire", false, "allow the use of insecure protocols")
+	fs.Var(insecureHostsFlag{}, "insecure-host", "host for which insecure protocols are allowed")
+	fs.BoolVar(&keepGoing, "keep-going", false, "keep updating after a dependency fails")
+	addNetworkFlags(fs)
+	fs.IntVar(&eventsFd, "events-fd", 0, "file descriptor to write JSON events to")
 }
 
 var cmdUpdate = &Command{
 	Name:      "update",
-	UsageLine: "update [-all] import",
+	UsageLine: "update [-all] [-revision rev] [-keep-going] import...",
 	Short:     "update a local dependency",
 	Long: `update will replaces the source with the latest available from the head of the master branch.
 
 Updating from one copy of a dependency to another comes with several restrictions.
 The first is you can only update to the head of the branch your dependency was vendored from, switching branches is not supported.
 The second restriction is if you have used -tag or -revision while vendoring a dependency, your dependency is "headless"
-(to borrow a term from git) and cannot be updated.
+(to borrow a term from git) and can only be moved to another revision with -revision.
 
-To update across branches, or from one tag/revision to another, you must first use delete to remove the dependency, then
+Dependencies fetched with -shallow are updated with a shallow clone too.
+Dependencies replaced with fetch -replace are copied, or symlinked, again
+from their local directory.
+Dependencies fetched with -flatten have their vendor directory moved again
+to the top-level one, replacing the packages hoisted from the old version.
+Those packages can't be updated on their own.
+Dependencies fetched with -minimal are trimmed again to the packages the
+project imports from the new version.
+
+To update across branches, or to a tag, you must first use delete to remove the dependency, then
 fetch [-tag | -revision | -branch ] [-precaire] to replace it.
 
 Flags:
 	-all
-		will update all dependencies in the manifest, otherwise only the dependency supplied.
+		will update all dependencies in the manifest, otherwise only the dependencies supplied.
+	-keep-going
+		when a dependency fails to update, carry on with the others and
+		report all the failures at the end.
 	-precaire
 		allow the use of insecure protocols.
+	-insecure-host host
+		allow the use of insecure protocols for host only, including
+		for the go-import metadata. Can be supplied multiple times.
+	-revision rev
+		update a single dependency to the given revision instead of the
+		head of its branch. The dependency is then headless.
+	-events-fd n
+		write a JSON object per line to file descriptor n for each
+		event: resolve, fetch-start, fetch-done, skip and error.
 
+The network flags, like -retries and -offline, are also accepted, see
+"gvt help network".
 `,
-	Run: func(args []string) error {
-		if len(args) != 1 && !updateAll {
-			return fmt.Errorf("update: import path or --all flag is missing")
-		} else if len(args) == 1 && updateAll {
-			return fmt.Errorf("update: you cannot specify path and --all flag at once")
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) == 0 && !updateAll {
+			return usageErrorf("update: import path or --all flag is missing")
+		} else if len(args) > 0 && updateAll {
+			return usageErrorf("update: you cannot specify path and --all flag at once")
+		}
+		if revision != "" && (len(args) != 1 || updateAll) {
+			return usageErrorf("update: -revision can only be used with a single import path")
 		}
 
 		m, err := vendor.ReadManifest(manifestFile())
 		if err != nil {
-			return fmt.Errorf("could not load manifest: %v", err)
+			return fmt.Errorf("could not load manifest: %w", err)
 		}
 
 		var dependencies []vendor.Dependency
@@ -55,74 +91,175 @@ Flags:
 			dependencies = make([]vendor.Dependency, len(m.Dependencies))
 			copy(dependencies, m.Dependencies)
 		} else {
-			p := args[0]
-			dependency, err := m.GetDependencyForImportpath(p)
-			if err != nil {
-				return fmt.Errorf("could not get dependency: %v", err)
+			for _, p := range args {
+				dependency, err := m.GetDependencyForImportpath(p)
+				if err != nil {
+					return fmt.Errorf("could not get dependency: %v", err)
+				}
+				dependencies = append(dependencies, dependency)
 			}
-			dependencies = append(dependencies, dependency)
 		}
 
+		var errs multiError
 		for _, d := range dependencies {
-			err = m.RemoveDependency(d)
-			if err != nil {
-				return fmt.Errorf("dependency could not be deleted from manifest: %v", err)
+			if err := ctx.Err(); err != nil {
+				return err
 			}
-
-			repo, extra, err := vendor.DeduceRemoteRepo(d.Importpath, insecure)
-			if err != nil {
-				return fmt.Errorf("could not determine repository for import %q", d.Importpath)
+			if d.Hoisted != "" && updateAll {
+				// updated along with d.Hoisted
+				continue
 			}
-
-			wc, err := repo.Checkout(d.Branch, "", "")
-			if err != nil {
-				return err
+			start := time.Now()
+			emit(event{Event: "fetch-start", Importpath: d.Importpath, Repository: d.Repository})
+			if err := updateDependency(ctx, m, d); err != nil {
+				emitError(d.Importpath, err)
+				if !keepGoing {
+					return err
+				}
+				log.Printf("could not update %s: %v", d.Importpath, err)
+				errs = append(errs, fmt.Errorf("%s: %w", d.Importpath, err))
+				continue
 			}
+			nd, _ := m.GetDependencyForImportpath(d.Importpath)
+			emit(event{Event: "fetch-done", Importpath: d.Importpath, Repository: nd.Repository,
+				Revision: nd.Revision, Duration: time.Since(start)})
+		}
+		if len(errs) > 0 {
+			return errs
+		}
 
-			rev, err := wc.Revision()
-			if err != nil {
-				return err
-			}
+		return nil
+	},
+	AddFlags: addUpdateFlags,
+	Locks:    true,
+}
 
-			branch, err := wc.Branch()
-			if err != nil {
-				return err
-			}
+// updateDependency replaces d with the latest revision of its branch, or
+// with revision if set, and writes the manifest.
+func updateDependency(ctx context.Context, m *vendor.Manifest, d vendor.Dependency) error {
+	if d.Replace != "" {
+		debugf("copying %s from %s", d.Importpath, d.Replace)
+		return placeReplacement(d)
+	}
+	if d.Hoisted != "" {
+		return fmt.Errorf("%s was moved out of the vendor directory of %s, update that instead", d.Importpath, d.Hoisted)
+	}
 
-			dep := vendor.Dependency{
-				Importpath: d.Importpath,
-				Repository: repo.URL(),
-				Revision:   rev,
-				Branch:     branch,
-				Path:       extra,
-			}
+	repo, extra, err := vendor.DeduceRemoteRepoContext(ctx, d.Importpath, insecure)
+	if err != nil {
+		return fetchError(fmt.Errorf("could not determine repository for import %q", d.Importpath))
+	}
 
-			if err := vendor.RemoveAll(filepath.Join(vendorDir(), filepath.FromSlash(d.Importpath))); err != nil {
-				// TODO(dfc) need to apply vendor.cleanpath here to remove intermediate directories.
-				return fmt.Errorf("dependency could not be deleted: %v", err)
-			}
+	branch := d.Branch
+	if revision != "" {
+		branch = ""
+	}
+	debugf("checking out %s", repo.URL())
+	wc, err := checkout(vendor.WithShallowClone(ctx, d.Shallow), repo, branch, "", revision)
+	if err != nil {
+		return err
+	}
+	defer wc.Destroy()
 
-			dst := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
-			src := filepath.Join(wc.Dir(), dep.Path)
+	rev, err := wc.Revision()
+	if err != nil {
+		return err
+	}
+	extra = majorVersionPath(wc, extra)
 
-			if err := vendor.Copypath(dst, src); err != nil {
-				return err
-			}
+	branch, err = wc.Branch()
+	if err != nil {
+		return err
+	}
 
-			if err := m.AddDependency(dep); err != nil {
-				return err
-			}
+	dep := vendor.Dependency{
+		Importpath: d.Importpath,
+		Repository: repo.URL(),
+		Revision:   rev,
+		Branch:     branch,
+		Path:       extra,
+		Pruned:     d.Pruned,
+		Shallow:    isShallow(wc),
+		Transitive: d.Transitive,
+		Test:       d.Test,
+		Flattened:  d.Flattened,
+		FetchedAt:  now(),
+	}
 
-			if err := vendor.WriteManifest(manifestFile(), m); err != nil {
-				return err
-			}
+	dst := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
+	src := filepath.Join(wc.Dir(), filepath.FromSlash(dep.Path))
 
-			if err := wc.Destroy(); err != nil {
-				return err
-			}
+	if dep.Pruned {
+		if err := vendor.PruneFiles(src); err != nil {
+			return err
 		}
+	}
 
-		return nil
-	},
-	AddFlags: addUpdateFlags,
+	// the new copy is staged next to the old one, which is only removed
+	// once the manifest is written, so that a failed update, with
+	// -keep-going, leaves both the manifest and the files as they were.
+	stage, err := ioutil.TempDir(vendorDir(), ".gvt-update")
+	if err != nil {
+		return err
+	}
+	defer vendor.RemoveAll(stage)
+	staged, old := filepath.Join(stage, "new"), filepath.Join(stage, "old")
+	if err := os.Mkdir(staged, 0755); err != nil {
+		return err
+	}
+	if err := vendor.Copypath(staged, src); err != nil {
+		return err
+	}
+
+	saved := append([]vendor.Dependency(nil), m.Dependencies...)
+	if err := os.Rename(dst, old); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("dependency could not be deleted: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(staged, dst); err != nil {
+		return err
+	}
+	rollback := true
+	defer func() {
+		if rollback {
+			// put the old entry and copy back
+			m.Dependencies = saved
+			vendor.RemoveAll(dst)
+			os.Rename(old, dst)
+		}
+	}()
+
+	if err := m.RemoveDependency(d); err != nil {
+		return fmt.Errorf("dependency could not be deleted from manifest: %v", err)
+	}
+	if dep.Flattened {
+		if err := removeHoisted(m, d.Importpath); err != nil {
+			return err
+		}
+		if err := flattenVendor(m, dep); err != nil {
+			return err
+		}
+	}
+
+	dep.Checksum, err = checksum(m, dep.Importpath)
+	if err != nil {
+		return err
+	}
+
+	if err := m.AddDependency(dep); err != nil {
+		return err
+	}
+	if len(d.Packages) > 0 {
+		if err := minimizeDependency(m, dep.Importpath); err != nil {
+			return err
+		}
+	}
+
+	if err := vendor.WriteManifest(manifestFile(), m); err != nil {
+		return err
+	}
+	rollback = false
+	return nil
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var cmdVerify = &Command{
+	Name:      "verify",
+	UsageLine: "verify",
+	Short:     "check vendored files against the manifest",
+	Long: `verify checks that the vendored source of every dependency in the manifest
+matches the checksum recorded when it was fetched.
+
+Each dependency that does not match is printed along with the reason,
+"missing" if its directory does not exist and "modified" if files were
+changed, added or removed. Dependencies fetched without a checksum are
+reported as "unverified", and dependencies replaced by a local directory
+with fetch -replace as "replaced" along with it, but do not cause a
+failure.
+
+verify does not access the network. It exits with status 4 if any
+dependency fails verification.
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("verify takes no arguments")
+		}
+		return verify()
+	},
+}
+
+func verify() error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+
+	failed, err := verifyDependencies(m)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return mismatchErrorf("%d dependencies failed verification", failed)
+	}
+	return nil
+}
+
+// verifyDependencies prints the dependencies of m which are missing,
+// modified, unverified or replaced, and returns how many are missing or modified.
+func verifyDependencies(m *vendor.Manifest) (int, error) {
+	var failed int
+	for _, dep := range m.Dependencies {
+		dir := filepath.Join(vendorDir(), filepath.FromSlash(dep.Importpath))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			fmt.Printf("missing\t%s\n", dep.Importpath)
+			failed++
+			continue
+		}
+		if dep.Replace != "" {
+			fmt.Printf("replaced\t%s\t%s\n", dep.Importpath, dep.Replace)
+			continue
+		}
+		if dep.Checksum == "" {
+			fmt.Printf("unverified\t%s\n", dep.Importpath)
+			continue
+		}
+		sum, err := checksum(m, dep.Importpath)
+		if err != nil {
+			return 0, fmt.Errorf("could not checksum %s: %v", dep.Importpath, err)
+		}
+		if sum != dep.Checksum {
+			fmt.Printf("modified\t%s\n", dep.Importpath)
+			failed++
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+// version is the version of gvt, set when building a release with
+//
+//	go build -ldflags "-X main.version=v1.2.3"
+var version = ""
+
+var cmdVersion = &Command{
+	Name:      "version",
+	UsageLine: "version",
+	Short:     "print the version of gvt",
+	Long: `version prints the version of gvt, the version of Go it was built with and
+the version of the manifest format it writes. Manifests of a later version
+are rejected by all commands, so that an older gvt can't drop what it
+doesn't know about.
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 0 {
+			return usageErrorf("version takes no arguments")
+		}
+		fmt.Printf("gvt version %s\n", gvtVersion())
+		fmt.Printf("go version %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		fmt.Printf("manifest version %d\n", vendor.ManifestVersion)
+		return nil
+	},
+}
+
+// gvtVersion returns version, or the module version gvt was installed at
+// if it was not set.
+func gvtVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "devel"
+}
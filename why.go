@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/FiloSottile/gvt/gbvendor"
+)
+
+var whyTests bool // include the imports of the project tests
+
+func addWhyFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&whyTests, "tests", false, "include the imports of the project tests")
+	fs.StringVar(&self, "self", "", "import path of the project")
+	addParseFlags(fs)
+}
+
+var cmdWhy = &Command{
+	Name:      "why",
+	UsageLine: "why [-tests] [-self importpath] [-follow-symlinks] [-skip-errors] [-provided prefix] [-exclude-dir pattern] [-platforms list] importpath",
+	Short:     "explain why a dependency is vendored",
+	Long: `why prints the shortest chain of imports from each package of the project
+that needs importpath, which can be a package or a dependency in the manifest,
+one chain per line.
+
+If no package of the project needs it, why says so. Such a dependency can be
+removed with prune.
+
+Flags:
+	-tests
+		include the imports of the tests of the project.
+	-self importpath
+		the import path of the project, used to name its packages. If not
+		supplied it is deduced from the location of the project in GOPATH.
+
+The parsing flags, like -provided and -platforms, are also accepted, see
+"gvt help parsing".
+`,
+	Run: func(ctx context.Context, args []string) error {
+		if len(args) != 1 {
+			return usageErrorf("why: a single import path is required")
+		}
+		return why(args[0])
+	},
+	AddFlags: addWhyFlags,
+}
+
+func why(target string) error {
+	m, err := vendor.ReadManifest(manifestFile())
+	if err != nil {
+		return fmt.Errorf("could not load manifest: %w", err)
+	}
+	defer skipParseErrors()()
+	dirs, err := vendor.ParsePackageImports(projectDir, whyTests, vendorDir())
+	if err != nil {
+		return fmt.Errorf("could not parse the project imports: %w", err)
+	}
+	pkgs, err := vendoredPackages(m)
+	if err != nil {
+		return err
+	}
+
+	// importers maps each package to the packages importing it
+	importers := make(map[string][]string)
+	var project []string
+	for dir, imports := range dirs {
+		name := projectPackage(dir)
+		project = append(project, name)
+		for i := range imports {
+			importers[i] = append(importers[i], name)
+		}
+	}
+	for p, pkg := range pkgs {
+		for _, i := range pkg.Imports {
+			importers[i] = append(importers[i], p)
+		}
+	}
+	// filled in map order, sorted so that ties always give the same chain
+	for _, s := range importers {
+		sort.Strings(s)
+	}
+
+	// walk the imports backwards from the target, next points each package
+	// reached to the following one in its shortest chain.
+	next := make(map[string]string)
+	var queue []string
+	for p := range importers {
+		if p == target || strings.HasPrefix(p, target+"/") {
+			next[p] = ""
+			queue = append(queue, p)
+		}
+	}
+	sort.Strings(queue)
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, i := range importers[p] {
+			if _, ok := next[i]; !ok {
+				next[i] = p
+				queue = append(queue, i)
+			}
+		}
+	}
+
+	sort.Strings(project)
+	var found bool
+	for _, p := range project {
+		if _, ok := next[p]; !ok {
+			continue
+		}
+		found = true
+		chain := []string{p}
+		for n := next[p]; n != ""; n = next[n] {
+			chain = append(chain, n)
+		}
+		fmt.Println(strings.Join(chain, " -> "))
+	}
+	if !found {
+		fmt.Printf("%s is not needed by the project\n", target)
+	}
+	return nil
+}
+
+// projectPackage returns the import path of the project package in dir,
+// relative to the project directory. If the import path of the project
+// isn't known it returns a relative import path.
+func projectPackage(dir string) string {
+	s := selfImportpath()
+	switch {
+	case dir == "." && s == "":
+		return "."
+	case s == "":
+		return "./" + dir
+	default:
+		return path.Join(s, dir)
+	}
+}